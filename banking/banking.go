@@ -0,0 +1,206 @@
+// Package banking contains the core account and ledger logic for cli-bank,
+// independent of any particular front end. Both the interactive CLI (see
+// package main) and the bankd gRPC server (see cmd/bankd) drive accounts
+// through a Service.
+package banking
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultCurrency is the currency a new account's first wallet is opened in.
+const DefaultCurrency = "USD"
+
+// Account is a user's identity and login credentials. Its money lives in one
+// or more Wallets, one per currency it holds (see Wallet).
+type Account struct {
+	ID        uint      `json:"ID" gorm:"primaryKey"`
+	FullName  string    `json:"fullName"`
+	Username  string    `json:"username" gorm:"unique;index"`
+	Password  string    `json:"password"`
+	LastLogin time.Time `json:"lastLogin"`
+	CreatedAt time.Time
+
+	// RuleScript is an optional Lua script evaluated on Deposit, Withdraw
+	// and Transfer against this account (see rules.go). RulesEnabled toggles
+	// whether it's actually consulted, so a saved script can be disabled
+	// without losing it.
+	RuleScript   string `json:"-" gorm:"type:text"`
+	RulesEnabled bool   `json:"rulesEnabled"`
+}
+
+// Transaction is a human-readable statement line for a Wallet: what the CLI
+// and bankd show a user asking "what happened to my money". It's written
+// alongside the LedgerEntry postings that are the actual source of truth for
+// balances (see ledger.go), linked to them by TransferID.
+type Transaction struct {
+	ID             uint      `json:"ID" gorm:"primaryKey"`
+	TransferID     string    `json:"transferId" gorm:"index"`
+	Time           time.Time `json:"time"`
+	Counterparty   string    `json:"counterparty"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	ClosingBalance float64   `json:"closingBalance"`
+	Message        string    `json:"message"`
+	Type           string    `json:"type"`
+	FXRate         float64   `json:"fxRate"`
+	Fee            float64   `json:"fee"`
+	AccountID      uint
+	WalletID       uint
+}
+
+var (
+	ErrAccountExists     = errors.New("account already taken")
+	ErrAccountNotFound   = errors.New("account not found")
+	ErrWrongPassword     = errors.New("incorrect password")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrWalletExists      = errors.New("currency sub-account already open")
+	ErrWalletNotFound    = errors.New("currency sub-account not found")
+)
+
+// Service exposes the banking operations that used to live directly in
+// main.go, backed by a Store. It holds no per-caller session state: callers
+// pass an account ID (or username, at login) on every call, which makes
+// Service safe to share across many concurrent clients such as the bankd
+// gRPC server.
+type Service struct {
+	store Store
+	rates RateProvider
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithRateProvider overrides the default static FX rate table, e.g. with a
+// live HTTP-backed RateProvider.
+func WithRateProvider(rates RateProvider) Option {
+	return func(s *Service) { s.rates = rates }
+}
+
+// NewService wires a Service to store. store's schema is expected to
+// already be current (see internal/migrations); NewService does not migrate
+// it.
+func NewService(store Store, opts ...Option) (*Service, error) {
+	s := &Service{store: store, rates: DefaultRateProvider{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Login verifies username/password and returns the account, updating
+// LastLogin. The account's previous LastLogin is returned alongside it so
+// callers can display "last seen" without a second query. An account whose
+// Password is still a legacy pre-Argon2id digest is transparently re-hashed
+// with Argon2id on a successful login (see verifyPassword).
+func (s *Service) Login(username, password string) (account Account, previousLastLogin time.Time, err error) {
+	if account, err = s.store.AccountByUsername(username); err != nil {
+		return Account{}, time.Time{}, err
+	}
+	if !verifyPassword(password, account.Password) {
+		return Account{}, time.Time{}, ErrWrongPassword
+	}
+	if isLegacyHash(account.Password) {
+		if account.Password, err = hashPassword(password); err != nil {
+			return Account{}, time.Time{}, err
+		}
+	}
+	previousLastLogin = account.LastLogin
+	account.LastLogin = time.Now()
+	if err = s.store.SaveAccount(&account); err != nil {
+		return Account{}, time.Time{}, err
+	}
+	return account, previousLastLogin, nil
+}
+
+// CreateAccount opens a new account with a DefaultCurrency wallet seeded
+// with a joining balance of 1000.
+func (s *Service) CreateAccount(username, fullName, password string) (Account, error) {
+	exists, err := s.store.AccountExists(username)
+	if err != nil {
+		return Account{}, err
+	}
+	if exists {
+		return Account{}, ErrAccountExists
+	}
+
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return Account{}, err
+	}
+	account := Account{
+		Username: username,
+		FullName: fullName,
+		Password: hashed,
+	}
+	const joiningAmount = 1000
+
+	err = s.store.WithinTransaction(func(store Store) error {
+		if err := store.CreateAccount(&account); err != nil {
+			return err
+		}
+		wallet := Wallet{AccountID: account.ID, Currency: DefaultCurrency}
+		if err := store.CreateWallet(&wallet); err != nil {
+			return err
+		}
+
+		transferID, err := newTransferID()
+		if err != nil {
+			return err
+		}
+		if err := s.post(store, transferID,
+			LedgerEntry{AccountID: account.ID, WalletID: externalWalletID, Amount: joiningAmount, Direction: Debit, Currency: DefaultCurrency},
+			LedgerEntry{AccountID: account.ID, WalletID: wallet.ID, Amount: joiningAmount, Direction: Credit, Currency: DefaultCurrency},
+		); err != nil {
+			return err
+		}
+		return store.CreateTransaction(&Transaction{
+			TransferID:     transferID,
+			Time:           time.Now(),
+			Amount:         joiningAmount,
+			Currency:       DefaultCurrency,
+			ClosingBalance: joiningAmount,
+			Message:        "joining bonus",
+			Type:           "C",
+			AccountID:      account.ID,
+			WalletID:       wallet.ID,
+		})
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+// ListTransactions returns the transactions for accountID in the half-open
+// range [start, end). An end of 0 means "through the last transaction".
+func (s *Service) ListTransactions(accountID uint, start, end int) ([]Transaction, error) {
+	transactions, err := s.store.TransactionsByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	l := len(transactions)
+	if end == 0 || end > l {
+		end = l
+	}
+	if start > end {
+		start = end
+	}
+	return transactions[start:end], nil
+}
+
+// GetAccount looks up an account by ID.
+func (s *Service) GetAccount(accountID uint) (Account, error) {
+	return s.store.AccountByID(accountID)
+}
+
+// DeleteAccount permanently removes an account and all of its wallets.
+func (s *Service) DeleteAccount(accountID uint) error {
+	return s.store.WithinTransaction(func(store Store) error {
+		if err := store.DeleteWalletsByAccount(accountID); err != nil {
+			return err
+		}
+		return store.DeleteAccount(accountID)
+	})
+}
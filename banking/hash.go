@@ -0,0 +1,95 @@
+package banking
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters used to hash new passwords. 64MB/3/4 matches the
+// OWASP baseline for interactive login: expensive enough to make offline
+// cracking impractical, cheap enough not to be noticed at login.
+const (
+	argon2idMemory      = 64 * 1024 // KiB
+	argon2idIterations  = 3
+	argon2idParallelism = 4
+	argon2idSaltLen     = 16
+	argon2idKeyLen      = 32
+)
+
+// argon2idPrefix marks a Password value as an Argon2id digest, as opposed
+// to the legacy sha256(password) digest cli-bank used to store (see
+// legacyHash and verifyPassword).
+const argon2idPrefix = "$argon2id$"
+
+// hashPassword derives an Argon2id digest for password, encoded as a
+// self-describing string carrying the parameters it was hashed with
+// ("$argon2id$v=<version>$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>"),
+// so verifyPassword can check it correctly even if the constants above
+// change later.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	sum := argon2.IDKey([]byte(password), salt, argon2idIterations, argon2idMemory, argon2idParallelism, argon2idKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, argon2idMemory, argon2idIterations, argon2idParallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(sum),
+	), nil
+}
+
+// verifyPassword reports whether password matches encoded, which may be an
+// Argon2id digest from hashPassword or a legacy sha256(password) digest
+// from before cli-bank switched to Argon2id. Service.Login re-hashes a
+// successful legacy match with hashPassword so accounts migrate to Argon2id
+// the first time their owner logs in.
+func verifyPassword(password, encoded string) bool {
+	if !isLegacyHash(encoded) {
+		return verifyArgon2id(password, encoded)
+	}
+	return subtle.ConstantTimeCompare([]byte(legacyHash(password)), []byte(encoded)) == 1
+}
+
+// isLegacyHash reports whether encoded is a pre-Argon2id sha256(password)
+// digest rather than one hashPassword produced.
+func isLegacyHash(encoded string) bool {
+	return !strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+func verifyArgon2id(password, encoded string) bool {
+	parts := strings.Split(strings.TrimPrefix(encoded, argon2idPrefix), "$")
+	if len(parts) != 4 {
+		return false
+	}
+	var memory, iterations uint64
+	var parallelism uint64
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got := argon2.IDKey([]byte(password), salt, uint32(iterations), uint32(memory), uint8(parallelism), uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// legacyHash reproduces cli-bank's original sha256(password) digest, kept
+// only so verifyPassword can still authenticate accounts created before the
+// Argon2id migration.
+func legacyHash(s string) string {
+	h := sha256.New()
+	h.Write([]byte(s))
+	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
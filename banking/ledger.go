@@ -0,0 +1,174 @@
+package banking
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Direction is which side of a LedgerEntry a posting sits on.
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+// externalWalletID is the sentinel WalletID used for the non-wallet leg of a
+// cash deposit or withdrawal: money entering or leaving the bank from
+// outside any tracked wallet.
+const externalWalletID = 0
+
+// LedgerEntry is one posting in the double-entry ledger. Wallet balances are
+// never stored directly; they're derived by summing postings (see
+// LedgerStore.WalletBalance). Every TransferID's entries, grouped by
+// Currency, sum to zero — see Service.CheckConsistency.
+type LedgerEntry struct {
+	ID         uint      `json:"ID" gorm:"primaryKey"`
+	TransferID string    `json:"transferId" gorm:"index"`
+	AccountID  uint      `json:"accountId"`
+	WalletID   uint      `json:"walletId" gorm:"index"`
+	Amount     float64   `json:"amount"`
+	Direction  Direction `json:"direction"`
+	Currency   string    `json:"currency"`
+	PostedAt   time.Time `json:"postedAt"`
+}
+
+// IdempotencyKey remembers which TransferID a clientRequestID already
+// produced, so a retried Deposit/Withdraw/Transfer call returns the original
+// result instead of posting twice.
+type IdempotencyKey struct {
+	ID              uint   `gorm:"primaryKey"`
+	ClientRequestID string `gorm:"uniqueIndex"`
+	TransferID      string
+}
+
+func newTransferID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// withIdempotency resolves clientRequestID to a TransferID: the one already
+// on file, if a matching IdempotencyKey exists, or a fresh one that fn posts
+// against and that gets recorded for next time. fn is not called on a
+// replay. clientRequestID == "" disables idempotency (a fresh TransferID is
+// used and nothing is recorded).
+//
+// The key is recorded before fn runs, not after, so two concurrent calls
+// with the same clientRequestID can't both pass the existence check above
+// and both post: RecordIdempotencyKey's unique constraint on ClientRequestID
+// lets only one of them win that race, and the loser picks up the winner's
+// TransferID via errIdempotencyKeyConflict instead of posting a second time.
+// This all runs inside the caller's own WithinTransaction, so if fn then
+// fails, the reservation rolls back with it, leaving a clean slate for the
+// next retry.
+func (s *Service) withIdempotency(store Store, clientRequestID string, fn func(transferID string) error) (string, error) {
+	if clientRequestID == "" {
+		transferID, err := newTransferID()
+		if err != nil {
+			return "", err
+		}
+		return transferID, fn(transferID)
+	}
+
+	existing, found, err := store.IdempotencyKeyFor(clientRequestID)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return existing.TransferID, nil
+	}
+
+	transferID, err := newTransferID()
+	if err != nil {
+		return "", err
+	}
+	if err := store.RecordIdempotencyKey(&IdempotencyKey{ClientRequestID: clientRequestID, TransferID: transferID}); err != nil {
+		if errors.Is(err, errIdempotencyKeyConflict) {
+			existing, found, err := store.IdempotencyKeyFor(clientRequestID)
+			if err != nil {
+				return "", err
+			}
+			if found {
+				return existing.TransferID, nil
+			}
+		}
+		return "", err
+	}
+
+	if err := fn(transferID); err != nil {
+		return "", err
+	}
+	return transferID, nil
+}
+
+// post writes entries as a single balanced TransferID. Callers are
+// responsible for ensuring that, grouped by currency, the entries sum to
+// zero.
+func (s *Service) post(store Store, transferID string, entries ...LedgerEntry) error {
+	now := time.Now()
+	for i := range entries {
+		entries[i].TransferID = transferID
+		entries[i].PostedAt = now
+	}
+	return store.CreateLedgerEntries(entries)
+}
+
+// Reverse posts compensating entries for transferID: every debit becomes a
+// credit and vice versa, under a new TransferID. History is never mutated.
+func (s *Service) Reverse(transferID string) (reversalID string, err error) {
+	err = s.store.WithinTransaction(func(store Store) error {
+		original, err := store.LedgerEntriesByTransferID(transferID)
+		if err != nil {
+			return err
+		}
+		if len(original) == 0 {
+			return fmt.Errorf("banking: transfer %q not found", transferID)
+		}
+
+		compensating := make([]LedgerEntry, len(original))
+		for i, e := range original {
+			direction := Credit
+			if e.Direction == Credit {
+				direction = Debit
+			}
+			compensating[i] = LedgerEntry{
+				AccountID: e.AccountID,
+				WalletID:  e.WalletID,
+				Amount:    e.Amount,
+				Direction: direction,
+				Currency:  e.Currency,
+			}
+		}
+
+		id, genErr := newTransferID()
+		if genErr != nil {
+			return genErr
+		}
+		reversalID = id
+		return s.post(store, reversalID, compensating...)
+	})
+	return reversalID, err
+}
+
+// CheckConsistency verifies that every transfer's postings sum to zero
+// within each currency. It's meant to run at startup, before a Service is
+// trusted with traffic.
+func (s *Service) CheckConsistency() error {
+	balances, err := s.store.TransferBalances()
+	if err != nil {
+		return err
+	}
+	for _, b := range balances {
+		if math.Abs(b.Net) > 1e-9 {
+			return fmt.Errorf("banking: transfer %q is unbalanced in %s by %.6f", b.TransferID, b.Currency, b.Net)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package banking_test
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/sainak/cli-bank/banking"
+	"github.com/sainak/cli-bank/internal/migrations"
+)
+
+// TestTransferSameCurrencyMovesBalanceDirectly guards the same-currency path
+// of Transfer: it must move amount straight from sender to receiver, with
+// no fee and no FX clearing account involved, and leave the ledger
+// balanced.
+func TestTransferSameCurrencyMovesBalanceDirectly(t *testing.T) {
+	svc := newTestService(t)
+	from, err := svc.CreateAccount("ledger-transfer-from", "From", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	to, err := svc.CreateAccount("ledger-transfer-to", "To", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	fromBalance, toBalance, err := svc.Transfer(from.ID, banking.DefaultCurrency, to.Username, banking.DefaultCurrency, 400, "")
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if fromBalance.Balance != 600 {
+		t.Fatalf("sender balance = %v, want 600", fromBalance.Balance)
+	}
+	if toBalance.Balance != 1400 {
+		t.Fatalf("receiver balance = %v, want 1400 (joining balance + 400)", toBalance.Balance)
+	}
+
+	transactions, err := svc.ListTransactions(from.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	last := transactions[len(transactions)-1]
+	if last.Fee != 0 {
+		t.Fatalf("same-currency transfer Fee = %v, want 0", last.Fee)
+	}
+	if last.FXRate != 1 {
+		t.Fatalf("same-currency transfer FXRate = %v, want 1", last.FXRate)
+	}
+
+	if err := svc.CheckConsistency(); err != nil {
+		t.Fatalf("CheckConsistency: %v", err)
+	}
+}
+
+// TestReverseCreatesCompensatingPostings guards Reverse: it must undo a
+// transfer's effect on every wallet it touched by posting the opposite
+// entries under a new TransferID, not by mutating the original postings,
+// and leave the ledger consistent.
+func TestReverseCreatesCompensatingPostings(t *testing.T) {
+	svc := newTestService(t)
+	account, err := svc.CreateAccount("ledger-reverse", "Reverse", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	if _, err := svc.Withdraw(account.ID, banking.DefaultCurrency, 300, ""); err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+	transactions, err := svc.ListTransactions(account.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	transferID := transactions[len(transactions)-1].TransferID
+
+	original, err := svc.ListTransactions(account.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	wantOriginalCount := len(original)
+
+	reversalID, err := svc.Reverse(transferID)
+	if err != nil {
+		t.Fatalf("Reverse: %v", err)
+	}
+	if reversalID == transferID {
+		t.Fatalf("reversalID = %q, want a new TransferID distinct from the original %q", reversalID, transferID)
+	}
+
+	balances, err := svc.ListBalances(account.ID)
+	if err != nil {
+		t.Fatalf("ListBalances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Balance != 1000 {
+		t.Fatalf("balances after Reverse = %+v, want a single 1000 balance (the pre-withdrawal amount)", balances)
+	}
+
+	afterReverse, err := svc.ListTransactions(account.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	if len(afterReverse) != wantOriginalCount {
+		t.Fatalf("Reverse posted %d Transaction rows, want 0: it only affects LedgerEntry postings, not the statement", len(afterReverse)-wantOriginalCount)
+	}
+
+	if err := svc.CheckConsistency(); err != nil {
+		t.Fatalf("CheckConsistency: %v", err)
+	}
+}
+
+// TestCheckConsistencyDetectsUnbalancedTransfer guards the other side of
+// CheckConsistency: a TransferID whose postings don't sum to zero within a
+// currency, which Deposit/Withdraw/Transfer should never produce, must be
+// reported rather than silently accepted. It reaches under Service to the
+// Store to write the bad posting directly, since there's no way to ask the
+// public API to unbalance the ledger.
+func TestCheckConsistencyDetectsUnbalancedTransfer(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(t)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := banking.NewGormStore(db)
+	svc, err := banking.NewService(store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	account, err := svc.CreateAccount("ledger-inconsistent", "Inconsistent", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	if err := svc.CheckConsistency(); err != nil {
+		t.Fatalf("CheckConsistency before corruption: %v", err)
+	}
+
+	err = store.CreateLedgerEntries([]banking.LedgerEntry{{
+		TransferID: "unbalanced-transfer",
+		AccountID:  account.ID,
+		WalletID:   1,
+		Amount:     50,
+		Direction:  banking.Debit,
+		Currency:   banking.DefaultCurrency,
+	}})
+	if err != nil {
+		t.Fatalf("CreateLedgerEntries: %v", err)
+	}
+
+	err = svc.CheckConsistency()
+	if err == nil {
+		t.Fatal("CheckConsistency after an unbalanced posting returned nil, want an error")
+	}
+	if !strings.Contains(err.Error(), "unbalanced-transfer") {
+		t.Fatalf("CheckConsistency error = %v, want it to name the unbalanced TransferID", err)
+	}
+}
+
+// TestTransferAppliesFXFeeAndRate guards the cross-currency path of
+// Transfer/postTransferLegs: the fee is taken in the sender's currency
+// before conversion, the applied rate comes from the Service's
+// RateProvider, and the two legs through the FX clearing account still
+// leave the whole ledger balanced.
+func TestTransferAppliesFXFeeAndRate(t *testing.T) {
+	svc := newTestService(t)
+	from, err := svc.CreateAccount("ledger-fx-from", "From", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	to, err := svc.CreateAccount("ledger-fx-to", "To", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	if _, err := svc.OpenWallet(to.ID, "EUR"); err != nil {
+		t.Fatalf("OpenWallet: %v", err)
+	}
+
+	rate, err := banking.DefaultRateProvider{}.Rate(banking.DefaultCurrency, "EUR")
+	if err != nil {
+		t.Fatalf("Rate: %v", err)
+	}
+	const amount = 1000.0
+	const feeRate = 0.005
+	wantFee := amount * feeRate
+	wantCredited := (amount - wantFee) * rate
+
+	fromBalance, toBalance, err := svc.Transfer(from.ID, banking.DefaultCurrency, to.Username, "EUR", amount, "")
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if wantFromBalance := 1000 - amount; fromBalance.Balance != wantFromBalance {
+		t.Fatalf("sender balance = %v, want %v", fromBalance.Balance, wantFromBalance)
+	}
+	if diff := toBalance.Balance - wantCredited; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("receiver balance = %v, want %v (credited at rate %v)", toBalance.Balance, wantCredited, rate)
+	}
+
+	transactions, err := svc.ListTransactions(from.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	last := transactions[len(transactions)-1]
+	if last.Fee != wantFee {
+		t.Fatalf("Fee = %v, want %v (%v%% of %v)", last.Fee, wantFee, feeRate*100, amount)
+	}
+	if last.FXRate != rate {
+		t.Fatalf("FXRate = %v, want %v", last.FXRate, rate)
+	}
+
+	if err := svc.CheckConsistency(); err != nil {
+		t.Fatalf("CheckConsistency: %v", err)
+	}
+}
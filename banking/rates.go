@@ -0,0 +1,52 @@
+package banking
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RateProvider looks up the exchange rate to convert 1 unit of from into to.
+type RateProvider interface {
+	// Rate returns how many units of to one unit of from is worth.
+	Rate(from, to string) (float64, error)
+}
+
+// DefaultRateProvider is a small static table good enough for tests and
+// demos. It is not suitable for production use.
+type DefaultRateProvider struct{}
+
+// staticRates holds, for each currency, how many USD one unit is worth.
+var staticRatesToUSD = map[string]float64{
+	"USD": 1,
+	"EUR": 1.08,
+	"GBP": 1.27,
+	"JPY": 0.0064,
+	"INR": 0.012,
+}
+
+func (DefaultRateProvider) Rate(from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	fromUSD, ok := staticRatesToUSD[from]
+	if !ok {
+		return 0, fmt.Errorf("banking: no static rate for currency %q", from)
+	}
+	toUSD, ok := staticRatesToUSD[to]
+	if !ok {
+		return 0, fmt.Errorf("banking: no static rate for currency %q", to)
+	}
+	return fromUSD / toUSD, nil
+}
+
+// HTTPRateProvider fetches live rates from an external FX API. It is a stub:
+// Endpoint and Client are exported so callers can point it at a real
+// provider, but Rate always returns an error until that's wired up.
+type HTTPRateProvider struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+func (p HTTPRateProvider) Rate(from, to string) (float64, error) {
+	return 0, fmt.Errorf("banking: HTTPRateProvider not yet implemented (wanted %s/%s from %s)", from, to, p.Endpoint)
+}
@@ -0,0 +1,72 @@
+package banking_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sainak/cli-bank/banking"
+)
+
+// TestRuleScriptTimesOutInsteadOfHanging guards against a rule script
+// wedging the database transaction it runs inside: an unbounded script must
+// fail with a timeout, not hang TestRuleScript (and, in Deposit/Withdraw/
+// Transfer, the transaction around it) forever.
+func TestRuleScriptTimesOutInsteadOfHanging(t *testing.T) {
+	svc := newTestService(t)
+	account, err := svc.CreateAccount("rules-timeout", "Rules Timeout", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.TestRuleScript(account.ID, "while true do end", banking.PendingOperation{Type: "deposit", Amount: 1})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("TestRuleScript with an infinite loop returned nil, want a timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("TestRuleScript with an infinite loop did not return within 5s")
+	}
+}
+
+// TestRuleScriptCannotReadHostFiles guards against the sandbox escape
+// BaseLib opens up: dofile/loadfile/load/loadstring can run or read
+// arbitrary files off the host filesystem (dofile needs no io library, and
+// loadfile leaks a file's contents through its parse-error message even
+// when the file isn't valid Lua). None of them should be reachable from a
+// rule script.
+func TestRuleScriptCannotReadHostFiles(t *testing.T) {
+	svc := newTestService(t)
+	account, err := svc.CreateAccount("rules-sandbox-escape", "Rules Sandbox Escape", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("do-not-leak-me"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+
+	for _, script := range []string{
+		`dofile("` + secret + `")`,
+		`return deny(tostring(select(2, loadfile("` + secret + `"))))`,
+		`load("return 1")()`,
+		`loadstring("return 1")()`,
+		`require("os")`,
+	} {
+		err := svc.TestRuleScript(account.ID, script, banking.PendingOperation{Type: "deposit", Amount: 1})
+		if err == nil {
+			t.Fatalf("script %q: got nil error, want a call-to-nil error", script)
+		}
+		if strings.Contains(err.Error(), "do-not-leak-me") {
+			t.Fatalf("script %q: leaked secret file contents through: %v", script, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package banking
+
+import "errors"
+
+// errIdempotencyKeyConflict is returned by RecordIdempotencyKey, instead of
+// the store's raw unique-constraint error, when clientRequestID was already
+// recorded by a concurrent call racing this one to post the same operation.
+// withIdempotency reacts to it by re-reading the winning key, the same as it
+// would for a key that was already on file when it first checked; it never
+// reaches a Deposit/Withdraw/Transfer caller.
+var errIdempotencyKeyConflict = errors.New("banking: idempotency key already recorded")
+
+// Store persists everything a Service needs: accounts, currency wallets,
+// statement transactions and ledger postings. Service talks to the database
+// only through Store, via function receivers, so it never holds a *gorm.DB
+// (or any other driver handle) directly and can be pointed at any backend
+// that implements this interface. NewGormStore is the default, GORM-backed
+// implementation.
+type Store interface {
+	AccountStore
+	WalletStore
+	TransactionStore
+	LedgerStore
+
+	// WithinTransaction runs fn against a Store scoped to a single database
+	// transaction: either every call fn makes through it commits together,
+	// or none of them do.
+	WithinTransaction(fn func(Store) error) error
+}
+
+// AccountStore persists Account rows.
+type AccountStore interface {
+	CreateAccount(a *Account) error
+	AccountExists(username string) (bool, error)
+	AccountByUsername(username string) (Account, error)
+	AccountByID(id uint) (Account, error)
+	SaveAccount(a *Account) error
+	DeleteAccount(id uint) error
+}
+
+// WalletStore persists Wallet rows.
+type WalletStore interface {
+	CreateWallet(w *Wallet) error
+	WalletExists(accountID uint, currency string) (bool, error)
+	WalletByAccountCurrency(accountID uint, currency string) (Wallet, error)
+	WalletsByAccount(accountID uint) ([]Wallet, error)
+	DeleteWalletsByAccount(accountID uint) error
+}
+
+// TransactionStore persists Transaction rows, the human-readable statement
+// lines shown alongside the ledger (see Transaction's doc comment).
+type TransactionStore interface {
+	CreateTransaction(t *Transaction) error
+	TransactionsByAccount(accountID uint) ([]Transaction, error)
+}
+
+// TransferBalance is one (TransferID, Currency) group's net postings, as
+// reported by LedgerStore.TransferBalances.
+type TransferBalance struct {
+	TransferID string
+	Currency   string
+	Net        float64
+}
+
+// LedgerStore persists LedgerEntry postings and the idempotency keys used to
+// make Deposit/Withdraw/Transfer safe to retry.
+type LedgerStore interface {
+	CreateLedgerEntries(entries []LedgerEntry) error
+	LedgerEntriesByTransferID(transferID string) ([]LedgerEntry, error)
+	WalletBalance(walletID uint) (float64, error)
+	TransferBalances() ([]TransferBalance, error)
+
+	IdempotencyKeyFor(clientRequestID string) (IdempotencyKey, bool, error)
+	// RecordIdempotencyKey must fail with errIdempotencyKeyConflict, not a
+	// raw driver error, when k.ClientRequestID is already taken.
+	RecordIdempotencyKey(k *IdempotencyKey) error
+}
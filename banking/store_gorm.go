@@ -0,0 +1,162 @@
+package banking
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// gormStore is the default Store, backed by a GORM database. Its schema is
+// brought up to date separately, by internal/migrations, rather than by
+// AutoMigrate on every startup.
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore wraps db as a Store. Callers are responsible for running
+// internal/migrations against db first (e.g. via the `bank migrate`
+// subcommand) so its schema is current.
+func NewGormStore(db *gorm.DB) Store {
+	// RecordIdempotencyKey needs to tell a unique-constraint violation apart
+	// from any other write failure, across whichever of the three drivers
+	// is in use, so translate driver errors to gorm's portable ones.
+	db.Config.TranslateError = true
+	return &gormStore{db: db}
+}
+
+func (g *gormStore) CreateAccount(a *Account) error {
+	return g.db.Create(a).Error
+}
+
+func (g *gormStore) AccountExists(username string) (bool, error) {
+	var exists bool
+	err := g.db.Model(&Account{}).
+		Select("count(*) > 0").
+		Where("username = ?", username).
+		Find(&exists).Error
+	return exists, err
+}
+
+func (g *gormStore) AccountByUsername(username string) (Account, error) {
+	var account Account
+	err := g.db.Where("username = ?", username).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Account{}, ErrAccountNotFound
+	}
+	return account, err
+}
+
+func (g *gormStore) AccountByID(id uint) (Account, error) {
+	var account Account
+	err := g.db.First(&account, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Account{}, ErrAccountNotFound
+	}
+	return account, err
+}
+
+func (g *gormStore) SaveAccount(a *Account) error {
+	return g.db.Save(a).Error
+}
+
+func (g *gormStore) DeleteAccount(id uint) error {
+	return g.db.Delete(&Account{}, id).Error
+}
+
+func (g *gormStore) CreateWallet(w *Wallet) error {
+	return g.db.Create(w).Error
+}
+
+func (g *gormStore) WalletExists(accountID uint, currency string) (bool, error) {
+	var exists bool
+	err := g.db.Model(&Wallet{}).
+		Select("count(*) > 0").
+		Where("account_id = ? AND currency = ?", accountID, currency).
+		Find(&exists).Error
+	return exists, err
+}
+
+func (g *gormStore) WalletByAccountCurrency(accountID uint, currency string) (Wallet, error) {
+	var wallet Wallet
+	err := g.db.Where("account_id = ? AND currency = ?", accountID, currency).First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Wallet{}, ErrWalletNotFound
+	}
+	return wallet, err
+}
+
+func (g *gormStore) WalletsByAccount(accountID uint) ([]Wallet, error) {
+	var wallets []Wallet
+	err := g.db.Where("account_id = ?", accountID).Find(&wallets).Error
+	return wallets, err
+}
+
+func (g *gormStore) DeleteWalletsByAccount(accountID uint) error {
+	return g.db.Where("account_id = ?", accountID).Delete(&Wallet{}).Error
+}
+
+func (g *gormStore) CreateTransaction(t *Transaction) error {
+	return g.db.Create(t).Error
+}
+
+func (g *gormStore) TransactionsByAccount(accountID uint) ([]Transaction, error) {
+	var transactions []Transaction
+	err := g.db.Where("account_id = ?", accountID).Find(&transactions).Error
+	return transactions, err
+}
+
+func (g *gormStore) CreateLedgerEntries(entries []LedgerEntry) error {
+	for i := range entries {
+		if err := g.db.Create(&entries[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *gormStore) LedgerEntriesByTransferID(transferID string) ([]LedgerEntry, error) {
+	var entries []LedgerEntry
+	err := g.db.Where("transfer_id = ?", transferID).Find(&entries).Error
+	return entries, err
+}
+
+func (g *gormStore) WalletBalance(walletID uint) (float64, error) {
+	var net float64
+	err := g.db.Model(&LedgerEntry{}).
+		Where("wallet_id = ?", walletID).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", Credit).
+		Scan(&net).Error
+	return net, err
+}
+
+func (g *gormStore) TransferBalances() ([]TransferBalance, error) {
+	var balances []TransferBalance
+	err := g.db.Model(&LedgerEntry{}).
+		Select("transfer_id AS transfer_id, currency AS currency, SUM(CASE WHEN direction = ? THEN amount ELSE -amount END) AS net", Credit).
+		Group("transfer_id, currency").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func (g *gormStore) IdempotencyKeyFor(clientRequestID string) (IdempotencyKey, bool, error) {
+	var existing IdempotencyKey
+	err := g.db.Where("client_request_id = ?", clientRequestID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return IdempotencyKey{}, false, nil
+	}
+	return existing, err == nil, err
+}
+
+func (g *gormStore) RecordIdempotencyKey(k *IdempotencyKey) error {
+	err := g.db.Create(k).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return errIdempotencyKeyConflict
+	}
+	return err
+}
+
+func (g *gormStore) WithinTransaction(fn func(Store) error) error {
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
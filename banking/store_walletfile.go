@@ -0,0 +1,260 @@
+package banking
+
+import "errors"
+
+// WalletFileStore is a Store backed by a single encrypted wallet file (see
+// walletfile.go) instead of a database. It holds exactly one Account,
+// matching the single-identity model `--wallet` mode is built around: the
+// same Service logic that drives the multi-user, GORM-backed CLI runs
+// unchanged against it, re-encrypting the file to disk after every mutation.
+type WalletFileStore struct {
+	path       string
+	passphrase string
+	data       WalletFileData
+
+	nextWalletID uint
+	nextTxID     uint
+	nextLedgerID uint
+}
+
+// NewWalletFileStore creates a brand-new, empty encrypted wallet file at
+// path and wraps it as a Store. Use Service.CreateAccount on the resulting
+// Service to populate it.
+func NewWalletFileStore(path, passphrase string) (*WalletFileStore, error) {
+	w := &WalletFileStore{path: path, passphrase: passphrase, nextWalletID: 1, nextTxID: 1, nextLedgerID: 1}
+	if err := CreateWalletFile(path, passphrase, w.data); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// OpenWalletFileStore decrypts the wallet file at path with passphrase and
+// wraps it as a Store. It returns ErrWrongPassphrase if passphrase is wrong.
+func OpenWalletFileStore(path, passphrase string) (*WalletFileStore, error) {
+	data, err := OpenWalletFile(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	w := &WalletFileStore{path: path, passphrase: passphrase, data: data, nextWalletID: 1, nextTxID: 1, nextLedgerID: 1}
+	for _, wallet := range data.Wallets {
+		if wallet.ID >= w.nextWalletID {
+			w.nextWalletID = wallet.ID + 1
+		}
+	}
+	for _, t := range data.Transactions {
+		if t.ID >= w.nextTxID {
+			w.nextTxID = t.ID + 1
+		}
+	}
+	for _, e := range data.LedgerEntries {
+		if e.ID >= w.nextLedgerID {
+			w.nextLedgerID = e.ID + 1
+		}
+	}
+	return w, nil
+}
+
+// Scrub deletes the store's wallet file from disk (see DeleteWalletFile),
+// e.g. after Service.DeleteAccount empties it.
+func (w *WalletFileStore) Scrub() error {
+	return DeleteWalletFile(w.path)
+}
+
+func (w *WalletFileStore) persist() error {
+	return SaveWalletFile(w.path, w.passphrase, w.data)
+}
+
+func (w *WalletFileStore) CreateAccount(a *Account) error {
+	a.ID = 1
+	w.data.Account = *a
+	return w.persist()
+}
+
+func (w *WalletFileStore) AccountExists(username string) (bool, error) {
+	return w.data.Account.ID != 0 && w.data.Account.Username == username, nil
+}
+
+func (w *WalletFileStore) AccountByUsername(username string) (Account, error) {
+	if w.data.Account.ID == 0 || w.data.Account.Username != username {
+		return Account{}, ErrAccountNotFound
+	}
+	return w.data.Account, nil
+}
+
+func (w *WalletFileStore) AccountByID(id uint) (Account, error) {
+	if w.data.Account.ID == 0 || w.data.Account.ID != id {
+		return Account{}, ErrAccountNotFound
+	}
+	return w.data.Account, nil
+}
+
+func (w *WalletFileStore) SaveAccount(a *Account) error {
+	if w.data.Account.ID == 0 || w.data.Account.ID != a.ID {
+		return ErrAccountNotFound
+	}
+	w.data.Account = *a
+	return w.persist()
+}
+
+func (w *WalletFileStore) DeleteAccount(id uint) error {
+	if w.data.Account.ID == 0 || w.data.Account.ID != id {
+		return ErrAccountNotFound
+	}
+	w.data.Account = Account{}
+	return w.persist()
+}
+
+func (w *WalletFileStore) CreateWallet(wallet *Wallet) error {
+	wallet.ID = w.nextWalletID
+	w.nextWalletID++
+	w.data.Wallets = append(w.data.Wallets, *wallet)
+	return w.persist()
+}
+
+func (w *WalletFileStore) WalletExists(accountID uint, currency string) (bool, error) {
+	_, err := w.WalletByAccountCurrency(accountID, currency)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, ErrWalletNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (w *WalletFileStore) WalletByAccountCurrency(accountID uint, currency string) (Wallet, error) {
+	for _, wallet := range w.data.Wallets {
+		if wallet.AccountID == accountID && wallet.Currency == currency {
+			return wallet, nil
+		}
+	}
+	return Wallet{}, ErrWalletNotFound
+}
+
+func (w *WalletFileStore) WalletsByAccount(accountID uint) ([]Wallet, error) {
+	var wallets []Wallet
+	for _, wallet := range w.data.Wallets {
+		if wallet.AccountID == accountID {
+			wallets = append(wallets, wallet)
+		}
+	}
+	return wallets, nil
+}
+
+func (w *WalletFileStore) DeleteWalletsByAccount(accountID uint) error {
+	kept := w.data.Wallets[:0]
+	for _, wallet := range w.data.Wallets {
+		if wallet.AccountID != accountID {
+			kept = append(kept, wallet)
+		}
+	}
+	w.data.Wallets = kept
+	return w.persist()
+}
+
+func (w *WalletFileStore) CreateTransaction(t *Transaction) error {
+	t.ID = w.nextTxID
+	w.nextTxID++
+	w.data.Transactions = append(w.data.Transactions, *t)
+	return w.persist()
+}
+
+func (w *WalletFileStore) TransactionsByAccount(accountID uint) ([]Transaction, error) {
+	var transactions []Transaction
+	for _, t := range w.data.Transactions {
+		if t.AccountID == accountID {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+func (w *WalletFileStore) CreateLedgerEntries(entries []LedgerEntry) error {
+	for i := range entries {
+		entries[i].ID = w.nextLedgerID
+		w.nextLedgerID++
+		w.data.LedgerEntries = append(w.data.LedgerEntries, entries[i])
+	}
+	return w.persist()
+}
+
+func (w *WalletFileStore) LedgerEntriesByTransferID(transferID string) ([]LedgerEntry, error) {
+	var entries []LedgerEntry
+	for _, e := range w.data.LedgerEntries {
+		if e.TransferID == transferID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (w *WalletFileStore) WalletBalance(walletID uint) (float64, error) {
+	var net float64
+	for _, e := range w.data.LedgerEntries {
+		if e.WalletID != walletID {
+			continue
+		}
+		if e.Direction == Credit {
+			net += e.Amount
+		} else {
+			net -= e.Amount
+		}
+	}
+	return net, nil
+}
+
+func (w *WalletFileStore) TransferBalances() ([]TransferBalance, error) {
+	type key struct {
+		transferID string
+		currency   string
+	}
+	nets := map[key]float64{}
+	for _, e := range w.data.LedgerEntries {
+		k := key{e.TransferID, e.Currency}
+		if e.Direction == Credit {
+			nets[k] += e.Amount
+		} else {
+			nets[k] -= e.Amount
+		}
+	}
+	balances := make([]TransferBalance, 0, len(nets))
+	for k, net := range nets {
+		balances = append(balances, TransferBalance{TransferID: k.transferID, Currency: k.currency, Net: net})
+	}
+	return balances, nil
+}
+
+func (w *WalletFileStore) IdempotencyKeyFor(clientRequestID string) (IdempotencyKey, bool, error) {
+	for _, k := range w.data.Idempotency {
+		if k.ClientRequestID == clientRequestID {
+			return k, true, nil
+		}
+	}
+	return IdempotencyKey{}, false, nil
+}
+
+func (w *WalletFileStore) RecordIdempotencyKey(k *IdempotencyKey) error {
+	w.data.Idempotency = append(w.data.Idempotency, *k)
+	return w.persist()
+}
+
+// WithinTransaction runs fn against w, rolling back its in-memory state (and
+// re-persisting that rollback) if fn returns an error. Every mutating
+// method already persists itself, so the snapshot exists only to undo the
+// partial writes a failed multi-step fn leaves behind.
+func (w *WalletFileStore) WithinTransaction(fn func(Store) error) error {
+	snapshot := w.data
+	snapshot.Wallets = append([]Wallet(nil), w.data.Wallets...)
+	snapshot.Transactions = append([]Transaction(nil), w.data.Transactions...)
+	snapshot.LedgerEntries = append([]LedgerEntry(nil), w.data.LedgerEntries...)
+	snapshot.Idempotency = append([]IdempotencyKey(nil), w.data.Idempotency...)
+
+	if err := fn(w); err != nil {
+		w.data = snapshot
+		if persistErr := w.persist(); persistErr != nil {
+			return persistErr
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package banking
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// crossCurrencyFeeRate is charged on the source amount whenever a Transfer
+// converts between currencies. Same-currency transfers are fee-free.
+const crossCurrencyFeeRate = 0.005
+
+// fxClearingUsername is a bank-owned account whose wallets absorb the two
+// legs of a cross-currency Transfer (sell fromCurrency, buy toCurrency), so
+// every transfer's postings still sum to zero within each currency. Its
+// balances are the bank's FX inventory; the fee stays there as revenue.
+const fxClearingUsername = "~fx-clearing"
+
+// Wallet is one of an Account's currency sub-accounts. An Account can hold
+// at most one Wallet per currency. Balance isn't stored: it's derived from
+// LedgerEntry postings, see LedgerStore.WalletBalance.
+type Wallet struct {
+	ID        uint   `json:"ID" gorm:"primaryKey"`
+	AccountID uint   `json:"accountId" gorm:"uniqueIndex:idx_account_currency"`
+	Currency  string `json:"currency" gorm:"uniqueIndex:idx_account_currency"`
+}
+
+// WalletBalance is a Wallet with its derived Balance filled in: the shape
+// callers actually want, since Wallet itself no longer carries a balance
+// column.
+type WalletBalance struct {
+	Wallet
+	Balance float64 `json:"balance"`
+}
+
+func (s *Service) withBalance(store Store, w Wallet) (WalletBalance, error) {
+	balance, err := store.WalletBalance(w.ID)
+	if err != nil {
+		return WalletBalance{}, err
+	}
+	return WalletBalance{Wallet: w, Balance: balance}, nil
+}
+
+// OpenWallet opens a new zero-balance currency sub-account for accountID.
+func (s *Service) OpenWallet(accountID uint, currency string) (Wallet, error) {
+	exists, err := s.store.WalletExists(accountID, currency)
+	if err != nil {
+		return Wallet{}, err
+	}
+	if exists {
+		return Wallet{}, ErrWalletExists
+	}
+
+	wallet := Wallet{AccountID: accountID, Currency: currency}
+	if err := s.store.CreateWallet(&wallet); err != nil {
+		return Wallet{}, err
+	}
+	return wallet, nil
+}
+
+// ListBalances returns every wallet held by accountID, one per currency,
+// with its derived balance.
+func (s *Service) ListBalances(accountID uint) ([]WalletBalance, error) {
+	wallets, err := s.store.WalletsByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]WalletBalance, len(wallets))
+	for i, w := range wallets {
+		wb, err := s.withBalance(s.store, w)
+		if err != nil {
+			return nil, err
+		}
+		balances[i] = wb
+	}
+	return balances, nil
+}
+
+// fxClearingWallet returns the bank's clearing wallet for currency, opening
+// both the clearing account and the wallet on first use.
+func (s *Service) fxClearingWallet(store Store, currency string) (Wallet, error) {
+	account, err := store.AccountByUsername(fxClearingUsername)
+	if errors.Is(err, ErrAccountNotFound) {
+		account = Account{Username: fxClearingUsername, FullName: "FX Clearing"}
+		if err := store.CreateAccount(&account); err != nil {
+			return Wallet{}, err
+		}
+	} else if err != nil {
+		return Wallet{}, err
+	}
+
+	wallet, err := store.WalletByAccountCurrency(account.ID, currency)
+	if errors.Is(err, ErrWalletNotFound) {
+		wallet = Wallet{AccountID: account.ID, Currency: currency}
+		if err := store.CreateWallet(&wallet); err != nil {
+			return Wallet{}, err
+		}
+		return wallet, nil
+	}
+	return wallet, err
+}
+
+// postTransferLegs posts a Transfer's ledger entries: a direct debit/credit
+// pair when the wallets share a currency, or two pairs through the FX
+// clearing account when they don't.
+func (s *Service) postTransferLegs(store Store, transferID string, fromAccountID uint, fromWallet Wallet, fromCurrency string, toAccountID uint, toWallet Wallet, toCurrency string, amount, credited float64) error {
+	if fromCurrency == toCurrency {
+		return s.post(store, transferID,
+			LedgerEntry{AccountID: fromAccountID, WalletID: fromWallet.ID, Amount: amount, Direction: Debit, Currency: fromCurrency},
+			LedgerEntry{AccountID: toAccountID, WalletID: toWallet.ID, Amount: amount, Direction: Credit, Currency: toCurrency},
+		)
+	}
+
+	sellClearing, err := s.fxClearingWallet(store, fromCurrency)
+	if err != nil {
+		return err
+	}
+	buyClearing, err := s.fxClearingWallet(store, toCurrency)
+	if err != nil {
+		return err
+	}
+
+	return s.post(store, transferID,
+		// leg 1 (fromCurrency): sender sells amount to clearing, fee included.
+		LedgerEntry{AccountID: fromAccountID, WalletID: fromWallet.ID, Amount: amount, Direction: Debit, Currency: fromCurrency},
+		LedgerEntry{AccountID: sellClearing.AccountID, WalletID: sellClearing.ID, Amount: amount, Direction: Credit, Currency: fromCurrency},
+		// leg 2 (toCurrency): clearing sells credited to receiver at the applied rate.
+		LedgerEntry{AccountID: buyClearing.AccountID, WalletID: buyClearing.ID, Amount: credited, Direction: Debit, Currency: toCurrency},
+		LedgerEntry{AccountID: toAccountID, WalletID: toWallet.ID, Amount: credited, Direction: Credit, Currency: toCurrency},
+	)
+}
+
+// Deposit credits amount to accountID's currency wallet. clientRequestID
+// makes the call idempotent: retrying with the same ID returns the original
+// result instead of crediting twice.
+func (s *Service) Deposit(accountID uint, currency string, amount float64, clientRequestID string) (WalletBalance, error) {
+	var wallet Wallet
+	err := s.store.WithinTransaction(func(store Store) error {
+		var err error
+		if wallet, err = store.WalletByAccountCurrency(accountID, currency); err != nil {
+			return err
+		}
+		_, err = s.withIdempotency(store, clientRequestID, func(transferID string) error {
+			balance, err := store.WalletBalance(wallet.ID)
+			if err != nil {
+				return err
+			}
+			if err := s.evaluateRules(store, accountID, PendingOperation{Type: "deposit", Amount: amount, Balance: balance, Time: time.Now()}); err != nil {
+				return err
+			}
+			if err := s.post(store, transferID,
+				LedgerEntry{AccountID: accountID, WalletID: externalWalletID, Amount: amount, Direction: Debit, Currency: currency},
+				LedgerEntry{AccountID: accountID, WalletID: wallet.ID, Amount: amount, Direction: Credit, Currency: currency},
+			); err != nil {
+				return err
+			}
+			balance, err = store.WalletBalance(wallet.ID)
+			if err != nil {
+				return err
+			}
+			return store.CreateTransaction(&Transaction{
+				TransferID:     transferID,
+				Time:           time.Now(),
+				Amount:         amount,
+				Currency:       currency,
+				ClosingBalance: balance,
+				Message:        "credited via cash deposit",
+				Type:           "C",
+				AccountID:      accountID,
+				WalletID:       wallet.ID,
+			})
+		})
+		return err
+	})
+	if err != nil {
+		return WalletBalance{}, err
+	}
+	return s.withBalance(s.store, wallet)
+}
+
+// Withdraw debits amount from accountID's currency wallet. clientRequestID
+// makes the call idempotent.
+func (s *Service) Withdraw(accountID uint, currency string, amount float64, clientRequestID string) (WalletBalance, error) {
+	var wallet Wallet
+	err := s.store.WithinTransaction(func(store Store) error {
+		var err error
+		if wallet, err = store.WalletByAccountCurrency(accountID, currency); err != nil {
+			return err
+		}
+		_, err = s.withIdempotency(store, clientRequestID, func(transferID string) error {
+			balance, err := store.WalletBalance(wallet.ID)
+			if err != nil {
+				return err
+			}
+			if balance < amount {
+				return ErrInsufficientFunds
+			}
+			if err := s.evaluateRules(store, accountID, PendingOperation{Type: "withdraw", Amount: amount, Balance: balance, Time: time.Now()}); err != nil {
+				return err
+			}
+			if err := s.post(store, transferID,
+				LedgerEntry{AccountID: accountID, WalletID: wallet.ID, Amount: amount, Direction: Debit, Currency: currency},
+				LedgerEntry{AccountID: accountID, WalletID: externalWalletID, Amount: amount, Direction: Credit, Currency: currency},
+			); err != nil {
+				return err
+			}
+			balance, err = store.WalletBalance(wallet.ID)
+			if err != nil {
+				return err
+			}
+			return store.CreateTransaction(&Transaction{
+				TransferID:     transferID,
+				Time:           time.Now(),
+				Amount:         amount,
+				Currency:       currency,
+				ClosingBalance: balance,
+				Message:        "debited via cash withdrawal",
+				Type:           "D",
+				AccountID:      accountID,
+				WalletID:       wallet.ID,
+			})
+		})
+		return err
+	})
+	if err != nil {
+		return WalletBalance{}, err
+	}
+	return s.withBalance(s.store, wallet)
+}
+
+// Transfer moves amount from fromAccountID's fromCurrency wallet to
+// toUsername's toCurrency wallet, converting through the FX clearing
+// account when the currencies differ. clientRequestID makes the call
+// idempotent.
+func (s *Service) Transfer(fromAccountID uint, fromCurrency, toUsername, toCurrency string, amount float64, clientRequestID string) (from WalletBalance, to WalletBalance, err error) {
+	var fromWallet, toWallet Wallet
+	err = s.store.WithinTransaction(func(store Store) error {
+		fromAccount, err := store.AccountByID(fromAccountID)
+		if err != nil {
+			return err
+		}
+		toAccount, err := store.AccountByUsername(toUsername)
+		if err != nil {
+			return err
+		}
+
+		if fromWallet, err = store.WalletByAccountCurrency(fromAccountID, fromCurrency); err != nil {
+			return err
+		}
+		if toWallet, err = store.WalletByAccountCurrency(toAccount.ID, toCurrency); err != nil {
+			return err
+		}
+
+		_, err = s.withIdempotency(store, clientRequestID, func(transferID string) error {
+			fromBalance, err := store.WalletBalance(fromWallet.ID)
+			if err != nil {
+				return err
+			}
+			if fromBalance < amount {
+				return ErrInsufficientFunds
+			}
+			if err := s.evaluateRules(store, fromAccountID, PendingOperation{Type: "transfer", Amount: amount, Counterparty: toUsername, Balance: fromBalance, Time: time.Now()}); err != nil {
+				return err
+			}
+
+			rate, fee, credited := 1.0, 0.0, amount
+			if fromCurrency != toCurrency {
+				fee = amount * crossCurrencyFeeRate
+				rate, err = s.rates.Rate(fromCurrency, toCurrency)
+				if err != nil {
+					return err
+				}
+				credited = (amount - fee) * rate
+			}
+
+			if err := s.postTransferLegs(store, transferID, fromAccountID, fromWallet, fromCurrency, toAccount.ID, toWallet, toCurrency, amount, credited); err != nil {
+				return err
+			}
+
+			fromBalance, err = store.WalletBalance(fromWallet.ID)
+			if err != nil {
+				return err
+			}
+			toBalance, err := store.WalletBalance(toWallet.ID)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			if err := store.CreateTransaction(&Transaction{
+				TransferID:     transferID,
+				Time:           now,
+				Counterparty:   toAccount.Username,
+				Amount:         amount,
+				Currency:       fromCurrency,
+				ClosingBalance: fromBalance,
+				Message:        fmt.Sprintf("transferred to %s", toAccount.Username),
+				Type:           "D",
+				FXRate:         rate,
+				Fee:            fee,
+				AccountID:      fromAccountID,
+				WalletID:       fromWallet.ID,
+			}); err != nil {
+				return err
+			}
+			return store.CreateTransaction(&Transaction{
+				TransferID:     transferID,
+				Time:           now,
+				Counterparty:   fromAccount.Username,
+				Amount:         credited,
+				Currency:       toCurrency,
+				ClosingBalance: toBalance,
+				Message:        fmt.Sprintf("received from %s", fromAccount.Username),
+				Type:           "C",
+				FXRate:         rate,
+				Fee:            fee,
+				AccountID:      toAccount.ID,
+				WalletID:       toWallet.ID,
+			})
+		})
+		return err
+	})
+	if err != nil {
+		return WalletBalance{}, WalletBalance{}, err
+	}
+
+	if from, err = s.withBalance(s.store, fromWallet); err != nil {
+		return WalletBalance{}, WalletBalance{}, err
+	}
+	if to, err = s.withBalance(s.store, toWallet); err != nil {
+		return WalletBalance{}, WalletBalance{}, err
+	}
+	return from, to, nil
+}
@@ -0,0 +1,145 @@
+package banking_test
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/sainak/cli-bank/banking"
+	"github.com/sainak/cli-bank/internal/migrations"
+)
+
+func newTestService(t *testing.T) *banking.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(t)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// sqlite serializes writers at the database level with a locking error,
+	// not a queue; a single pooled connection turns concurrent callers (see
+	// TestWithdrawConcurrentRetriesReturnOriginalResult) into a queue on the
+	// Go side instead, which is what every other backend gives you for free.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("underlying sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc, err := banking.NewService(banking.NewGormStore(db))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return svc
+}
+
+// TestWithdrawReplayReturnsOriginalResult guards against a retried Withdraw
+// re-checking the balance against what the first, already-posted attempt
+// left behind: a retry with the same clientRequestID must return the
+// original result, not ErrInsufficientFunds.
+func TestWithdrawReplayReturnsOriginalResult(t *testing.T) {
+	svc := newTestService(t)
+	account, err := svc.CreateAccount("withdraw-replay", "Withdraw Replay", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	const clientRequestID = "req-1"
+	first, err := svc.Withdraw(account.ID, banking.DefaultCurrency, 1000, clientRequestID)
+	if err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+	if first.Balance != 0 {
+		t.Fatalf("Withdraw balance = %v, want 0", first.Balance)
+	}
+
+	replay, err := svc.Withdraw(account.ID, banking.DefaultCurrency, 1000, clientRequestID)
+	if err != nil {
+		t.Fatalf("replayed Withdraw returned an error instead of the original result: %v", err)
+	}
+	if replay.Balance != first.Balance {
+		t.Fatalf("replayed Withdraw balance = %v, want %v (the original result)", replay.Balance, first.Balance)
+	}
+}
+
+// TestWithdrawConcurrentRetriesReturnOriginalResult guards against two
+// concurrent retries of the same clientRequestID both passing
+// IdempotencyKeyFor's existence check before either has recorded a key, and
+// so both posting a Withdraw: only one may actually post, and the other
+// must come back with that one's result instead of a raw duplicate-key
+// error or an extra debit.
+func TestWithdrawConcurrentRetriesReturnOriginalResult(t *testing.T) {
+	svc := newTestService(t)
+	account, err := svc.CreateAccount("withdraw-concurrent-replay", "Withdraw Concurrent Replay", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	const clientRequestID = "req-concurrent"
+	const attempts = 8
+
+	var wg sync.WaitGroup
+	results := make([]banking.WalletBalance, attempts)
+	errs := make([]error, attempts)
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = svc.Withdraw(account.ID, banking.DefaultCurrency, 100, clientRequestID)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("attempt %d: Withdraw returned an error instead of the shared result: %v", i, err)
+		}
+		if results[i].Balance != results[0].Balance {
+			t.Fatalf("attempt %d balance = %v, want %v (every attempt should share the same result)", i, results[i].Balance, results[0].Balance)
+		}
+	}
+
+	final, err := svc.ListBalances(account.ID)
+	if err != nil {
+		t.Fatalf("ListBalances: %v", err)
+	}
+	if len(final) != 1 || final[0].Balance != 900 {
+		t.Fatalf("final balances = %+v, want a single 900 balance (withdrawn once despite %d concurrent attempts)", final, attempts)
+	}
+}
+
+// TestTransferReplayReturnsOriginalResult is Transfer's equivalent of
+// TestWithdrawReplayReturnsOriginalResult: a retry must not re-run the
+// balance check against the post-transfer balance.
+func TestTransferReplayReturnsOriginalResult(t *testing.T) {
+	svc := newTestService(t)
+	from, err := svc.CreateAccount("transfer-replay-from", "From", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	to, err := svc.CreateAccount("transfer-replay-to", "To", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	const clientRequestID = "req-1"
+	fromFirst, _, err := svc.Transfer(from.ID, banking.DefaultCurrency, to.Username, banking.DefaultCurrency, 1000, clientRequestID)
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if fromFirst.Balance != 0 {
+		t.Fatalf("Transfer sender balance = %v, want 0", fromFirst.Balance)
+	}
+
+	fromReplay, _, err := svc.Transfer(from.ID, banking.DefaultCurrency, to.Username, banking.DefaultCurrency, 1000, clientRequestID)
+	if err != nil {
+		t.Fatalf("replayed Transfer returned an error instead of the original result: %v", err)
+	}
+	if fromReplay.Balance != fromFirst.Balance {
+		t.Fatalf("replayed Transfer sender balance = %v, want %v (the original result)", fromReplay.Balance, fromFirst.Balance)
+	}
+}
@@ -0,0 +1,177 @@
+package banking
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// WalletFileVersion is the current on-disk format of an encrypted --wallet
+// file. Bump it, and branch on envelope.Version in OpenWalletFile, if the
+// envelope or plaintext shape ever needs to change incompatibly.
+const WalletFileVersion = 1
+
+// WalletFileKDF are the Argon2id parameters a wallet file was encrypted
+// with, recorded alongside its salt so it can still be opened if the
+// defaults below change later.
+type WalletFileKDF struct {
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// DefaultWalletFileKDF is what new wallet files are encrypted with: 64MB of
+// memory, 3 iterations, 4 lanes, matching the OWASP baseline for interactive
+// use.
+var DefaultWalletFileKDF = WalletFileKDF{Memory: 64 * 1024, Iterations: 3, Parallelism: 4}
+
+const (
+	walletFileSaltLen = 16
+	walletFileKeyLen  = 32 // AES-256
+)
+
+// walletFileEnvelope is the on-disk JSON shape of a --wallet file: a
+// versioned, self-describing AES-256-GCM ciphertext. It never round-trips
+// through anything but CreateWalletFile/OpenWalletFile, so a corrupt or
+// tampered envelope fails closed rather than partially decoding.
+type walletFileEnvelope struct {
+	Version    int           `json:"version"`
+	KDF        WalletFileKDF `json:"kdf"`
+	Salt       []byte        `json:"salt"`
+	Nonce      []byte        `json:"nonce"`
+	Ciphertext []byte        `json:"ciphertext"`
+}
+
+// WalletFileData is the plaintext an encrypted wallet file decrypts to: one
+// account and everything derived from it, self-contained so `--wallet` mode
+// needs no companion database.
+type WalletFileData struct {
+	Account       Account          `json:"account"`
+	Wallets       []Wallet         `json:"wallets"`
+	Transactions  []Transaction    `json:"transactions"`
+	LedgerEntries []LedgerEntry    `json:"ledgerEntries"`
+	Idempotency   []IdempotencyKey `json:"idempotency"`
+}
+
+// ErrWrongPassphrase is returned by OpenWalletFile when passphrase can't
+// decrypt the file at path, whether because it's wrong or the file is
+// corrupt: AES-GCM can't tell the two apart.
+var ErrWrongPassphrase = errors.New("banking: wrong passphrase or corrupt wallet file")
+
+// CreateWalletFile derives a key from passphrase with Argon2id, encrypts
+// data with AES-256-GCM under DefaultWalletFileKDF, and writes the result to
+// path. It refuses to overwrite an existing file.
+func CreateWalletFile(path, passphrase string, data WalletFileData) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("banking: wallet file %q already exists", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return SaveWalletFile(path, passphrase, data)
+}
+
+// SaveWalletFile re-encrypts data under a freshly derived key and overwrites
+// path, e.g. after a deposit or withdrawal changes what it holds.
+func SaveWalletFile(path, passphrase string, data WalletFileData) error {
+	salt := make([]byte, walletFileSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	kdf := DefaultWalletFileKDF
+	key := argon2.IDKey([]byte(passphrase), salt, kdf.Iterations, kdf.Memory, kdf.Parallelism, walletFileKeyLen)
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	encoded, err := json.MarshalIndent(walletFileEnvelope{
+		Version:    WalletFileVersion,
+		KDF:        kdf,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, encoded, 0o600)
+}
+
+// OpenWalletFile decrypts path with passphrase. The returned plaintext is
+// only ever held in memory; callers are responsible for not persisting it
+// anywhere but back through SaveWalletFile.
+func OpenWalletFile(path, passphrase string) (WalletFileData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return WalletFileData{}, err
+	}
+	var envelope walletFileEnvelope
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return WalletFileData{}, fmt.Errorf("banking: corrupt wallet file: %w", err)
+	}
+	if envelope.Version != WalletFileVersion {
+		return WalletFileData{}, fmt.Errorf("banking: unsupported wallet file version %d", envelope.Version)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), envelope.Salt, envelope.KDF.Iterations, envelope.KDF.Memory, envelope.KDF.Parallelism, walletFileKeyLen)
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return WalletFileData{}, err
+	}
+	if len(envelope.Nonce) != gcm.NonceSize() {
+		return WalletFileData{}, ErrWrongPassphrase
+	}
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return WalletFileData{}, ErrWrongPassphrase
+	}
+
+	var data WalletFileData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return WalletFileData{}, fmt.Errorf("banking: corrupt wallet file: %w", err)
+	}
+	return data, nil
+}
+
+// DeleteWalletFile scrubs path: it overwrites the file with zero bytes
+// before removing it, so neither the ciphertext nor anything that could
+// help brute-force the passphrase lingers in the filesystem's free space.
+// Deleting a path that doesn't exist is not an error.
+func DeleteWalletFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.WriteFile(path, make([]byte, info.Size()), 0o600); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,181 @@
+package banking_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/sainak/cli-bank/banking"
+	"github.com/sainak/cli-bank/internal/migrations"
+)
+
+// TestWalletFileRoundTrip guards CreateWalletFile/OpenWalletFile's envelope
+// format directly: what SaveWalletFile encrypts must be exactly what
+// OpenWalletFile decrypts back, salt and nonce included in the round trip
+// only incidentally (they're regenerated on every save, never compared).
+func TestWalletFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	data := banking.WalletFileData{
+		Account: banking.Account{ID: 1, Username: "wallet-file-roundtrip", FullName: "Wallet File Roundtrip"},
+		Wallets: []banking.Wallet{{ID: 1, AccountID: 1, Currency: banking.DefaultCurrency}},
+	}
+
+	if err := banking.CreateWalletFile(path, "correct horse battery staple", data); err != nil {
+		t.Fatalf("CreateWalletFile: %v", err)
+	}
+
+	got, err := banking.OpenWalletFile(path, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("OpenWalletFile: %v", err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Fatalf("OpenWalletFile = %+v, want %+v", got, data)
+	}
+}
+
+// TestCreateWalletFileRefusesToOverwrite guards the "opens a brand-new file"
+// half of CreateWalletFile's contract: it must never clobber an existing
+// wallet file, even with the right passphrase.
+func TestCreateWalletFileRefusesToOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	if err := banking.CreateWalletFile(path, "passphrase", banking.WalletFileData{}); err != nil {
+		t.Fatalf("CreateWalletFile: %v", err)
+	}
+	if err := banking.CreateWalletFile(path, "passphrase", banking.WalletFileData{}); err == nil {
+		t.Fatal("second CreateWalletFile at the same path returned nil, want an error")
+	}
+}
+
+// TestOpenWalletFileWrongPassphrase guards ErrWrongPassphrase: AES-GCM can't
+// tell a wrong key apart from a corrupt file, so OpenWalletFile must map
+// both to the same sentinel rather than a raw decryption error.
+func TestOpenWalletFileWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	if err := banking.CreateWalletFile(path, "right passphrase", banking.WalletFileData{}); err != nil {
+		t.Fatalf("CreateWalletFile: %v", err)
+	}
+
+	_, err := banking.OpenWalletFile(path, "wrong passphrase")
+	if !errors.Is(err, banking.ErrWrongPassphrase) {
+		t.Fatalf("OpenWalletFile with the wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+// TestWalletFileStoreRoundTrip drives a WalletFileStore the way `--wallet`
+// mode does: create an account and move money through a Service, then
+// reopen the same file as a fresh Store and Service, and confirm the
+// balance survived the round trip through disk.
+func TestWalletFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+
+	store, err := banking.NewWalletFileStore(path, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("NewWalletFileStore: %v", err)
+	}
+	svc, err := banking.NewService(store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	account, err := svc.CreateAccount("wallet-file-store", "Wallet File Store", "password123")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	deposited, err := svc.Deposit(account.ID, banking.DefaultCurrency, 500, "")
+	if err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+
+	reopened, err := banking.OpenWalletFileStore(path, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("OpenWalletFileStore: %v", err)
+	}
+	reopenedSvc, err := banking.NewService(reopened)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	balances, err := reopenedSvc.ListBalances(account.ID)
+	if err != nil {
+		t.Fatalf("ListBalances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Balance != deposited.Balance {
+		t.Fatalf("reopened balances = %+v, want a single %v balance", balances, deposited.Balance)
+	}
+}
+
+// TestOpenWalletFileStoreWrongPassphrase is TestOpenWalletFileWrongPassphrase's
+// equivalent one layer up, through the Store constructor `--wallet` mode
+// actually calls.
+func TestOpenWalletFileStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	if _, err := banking.NewWalletFileStore(path, "right passphrase"); err != nil {
+		t.Fatalf("NewWalletFileStore: %v", err)
+	}
+
+	_, err := banking.OpenWalletFileStore(path, "wrong passphrase")
+	if !errors.Is(err, banking.ErrWrongPassphrase) {
+		t.Fatalf("OpenWalletFileStore with the wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+// legacyPasswordHash reproduces the pre-Argon2id digest cli-bank used to
+// store: base64url(sha256(password)), with none of the "$argon2id$..."
+// framing hashPassword adds. It exists only so this test can fabricate an
+// account stuck on the old format without a time machine.
+func legacyPasswordHash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return base64.URLEncoding.EncodeToString(sum[:])
+}
+
+// TestLoginMigratesLegacyHashToArgon2id guards Service.Login's transparent
+// re-hash: an account whose Password is still the old sha256 digest must
+// authenticate correctly on its first login under the new scheme, and come
+// out the other side stored as Argon2id so every login after that is too.
+func TestLoginMigratesLegacyHashToArgon2id(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(t)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := banking.NewGormStore(db)
+	svc, err := banking.NewService(store)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	const password = "password123"
+	account, err := svc.CreateAccount("legacy-hash-login", "Legacy Hash Login", password)
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	account.Password = legacyPasswordHash(password)
+	if err := store.SaveAccount(&account); err != nil {
+		t.Fatalf("SaveAccount: %v", err)
+	}
+
+	if _, _, err := svc.Login(account.Username, password); err != nil {
+		t.Fatalf("Login against a legacy hash: %v", err)
+	}
+
+	migrated, err := svc.GetAccount(account.ID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if !strings.HasPrefix(migrated.Password, "$argon2id$") {
+		t.Fatalf("Password after a successful legacy login = %q, want it re-hashed as Argon2id", migrated.Password)
+	}
+
+	if _, _, err := svc.Login(account.Username, password); err != nil {
+		t.Fatalf("Login after the Argon2id migration: %v", err)
+	}
+}
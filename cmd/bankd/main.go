@@ -0,0 +1,73 @@
+// Command bankd serves the banking API over gRPC, with a REST/JSON gateway
+// for clients that can't speak gRPC directly (curl, browsers). It's a thin
+// transport shell around banking.Service; all the actual account logic
+// lives there so the CLI and bankd stay in sync.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+
+	"github.com/sainak/cli-bank/banking"
+	bankingv1 "github.com/sainak/cli-bank/gen/banking/v1"
+	"github.com/sainak/cli-bank/internal/config"
+	"github.com/sainak/cli-bank/internal/migrations"
+)
+
+var (
+	grpcAddr = flag.String("grpc-addr", ":8080", "address for the gRPC listener")
+	httpAddr = flag.String("http-addr", ":8081", "address for the REST gateway")
+)
+
+func main() {
+	flag.Parse()
+
+	db, err := config.FromEnv().Open()
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	if err := migrations.Run(db); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	svc, err := banking.NewService(banking.NewGormStore(db))
+	if err != nil {
+		log.Fatalf("init banking service: %v", err)
+	}
+	if err := svc.CheckConsistency(); err != nil {
+		log.Fatalf("ledger consistency check failed: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Fatalf("listen %s: %v", *grpcAddr, err)
+	}
+
+	srv := newBankingServer(svc)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(srv.authUnaryInterceptor))
+	bankingv1.RegisterBankingServiceServer(grpcServer, srv)
+
+	go func() {
+		log.Printf("bankd: gRPC listening on %s", *grpcAddr)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("serve grpc: %v", err)
+		}
+	}()
+
+	mux := runtime.NewServeMux()
+	ctx := context.Background()
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if err := bankingv1.RegisterBankingServiceHandlerFromEndpoint(ctx, mux, *grpcAddr, opts); err != nil {
+		log.Fatalf("register gateway: %v", err)
+	}
+
+	log.Printf("bankd: REST gateway listening on %s", *httpAddr)
+	if err := http.ListenAndServe(*httpAddr, mux); err != nil {
+		log.Fatalf("serve http: %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sainak/cli-bank/banking"
+	bankingv1 "github.com/sainak/cli-bank/gen/banking/v1"
+)
+
+// bankingServer adapts banking.Service to the generated BankingServiceServer
+// interface, translating between the wire types and banking.Account/Transaction
+// and mapping banking errors to gRPC status codes.
+type bankingServer struct {
+	bankingv1.UnimplementedBankingServiceServer
+
+	svc *banking.Service
+
+	mu     sync.RWMutex
+	tokens map[string]uint // bearer token -> account ID
+}
+
+func newBankingServer(svc *banking.Service) *bankingServer {
+	return &bankingServer{svc: svc, tokens: make(map[string]uint)}
+}
+
+func (s *bankingServer) Login(_ context.Context, req *bankingv1.LoginRequest) (*bankingv1.LoginResponse, error) {
+	account, _, err := s.svc.Login(req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	token, err := newBearerToken()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "issue token: %v", err)
+	}
+	s.mu.Lock()
+	s.tokens[token] = account.ID
+	s.mu.Unlock()
+
+	return &bankingv1.LoginResponse{
+		BearerToken: token,
+		Account:     toProtoAccount(account),
+	}, nil
+}
+
+func (s *bankingServer) OpenAccount(_ context.Context, req *bankingv1.OpenAccountRequest) (*bankingv1.OpenAccountResponse, error) {
+	account, err := s.svc.CreateAccount(req.GetUsername(), req.GetFullName(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.OpenAccountResponse{Account: toProtoAccount(account)}, nil
+}
+
+func (s *bankingServer) CloseAccount(ctx context.Context, req *bankingv1.CloseAccountRequest) (*bankingv1.CloseAccountResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot close another account")
+	}
+	if err := s.svc.DeleteAccount(accountID); err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.CloseAccountResponse{}, nil
+}
+
+func (s *bankingServer) GetAccount(ctx context.Context, req *bankingv1.GetAccountRequest) (*bankingv1.GetAccountResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot view another account")
+	}
+	account, err := s.svc.GetAccount(accountID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.GetAccountResponse{Account: toProtoAccount(account)}, nil
+}
+
+func (s *bankingServer) OpenWallet(ctx context.Context, req *bankingv1.OpenWalletRequest) (*bankingv1.OpenWalletResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot open a wallet for another account")
+	}
+	wallet, err := s.svc.OpenWallet(accountID, req.GetCurrency())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.OpenWalletResponse{Wallet: toProtoWallet(banking.WalletBalance{Wallet: wallet})}, nil
+}
+
+func (s *bankingServer) ListBalances(ctx context.Context, req *bankingv1.ListBalancesRequest) (*bankingv1.ListBalancesResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot view another account's wallets")
+	}
+	wallets, err := s.svc.ListBalances(accountID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &bankingv1.ListBalancesResponse{Wallets: make([]*bankingv1.Wallet, len(wallets))}
+	for i, w := range wallets {
+		resp.Wallets[i] = toProtoWallet(w)
+	}
+	return resp, nil
+}
+
+func (s *bankingServer) Deposit(ctx context.Context, req *bankingv1.DepositRequest) (*bankingv1.DepositResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot deposit into another account")
+	}
+	wallet, err := s.svc.Deposit(accountID, req.GetCurrency(), req.GetAmount(), req.GetClientRequestId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.DepositResponse{Wallet: toProtoWallet(wallet)}, nil
+}
+
+func (s *bankingServer) Withdraw(ctx context.Context, req *bankingv1.WithdrawRequest) (*bankingv1.WithdrawResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot withdraw from another account")
+	}
+	wallet, err := s.svc.Withdraw(accountID, req.GetCurrency(), req.GetAmount(), req.GetClientRequestId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.WithdrawResponse{Wallet: toProtoWallet(wallet)}, nil
+}
+
+func (s *bankingServer) Transfer(ctx context.Context, req *bankingv1.TransferRequest) (*bankingv1.TransferResponse, error) {
+	accountID, err := accountIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uint(req.GetFromAccountId()) != accountID {
+		return nil, status.Error(codes.PermissionDenied, "cannot transfer from another account")
+	}
+	from, to, err := s.svc.Transfer(accountID, req.GetFromCurrency(), req.GetToUsername(), req.GetToCurrency(), req.GetAmount(), req.GetClientRequestId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bankingv1.TransferResponse{From: toProtoWallet(from), To: toProtoWallet(to)}, nil
+}
+
+func (s *bankingServer) ListTransactions(req *bankingv1.ListTransactionsRequest, stream bankingv1.BankingService_ListTransactionsServer) error {
+	accountID, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	if uint(req.GetAccountId()) != accountID {
+		return status.Error(codes.PermissionDenied, "cannot list another account's transactions")
+	}
+	transactions, err := s.svc.ListTransactions(accountID, 0, 0)
+	if err != nil {
+		return toStatus(err)
+	}
+	for _, t := range transactions {
+		if err := stream.Send(toProtoTransaction(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authenticate resolves the bearer token in ctx's metadata to an account ID.
+func (s *bankingServer) authenticate(ctx context.Context) (uint, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	tokens := md.Get("authorization")
+	if len(tokens) == 0 {
+		return 0, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	s.mu.RLock()
+	accountID, ok := s.tokens[bearerToken(tokens[0])]
+	s.mu.RUnlock()
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "invalid or expired bearer token")
+	}
+	return accountID, nil
+}
+
+// accountIDContextKey is the key authUnaryInterceptor stores an
+// authenticated caller's account ID under.
+type accountIDContextKey struct{}
+
+// unaryMethodsWithoutAuth lists the unary RPCs reachable without a bearer
+// token: there's no account to authenticate against yet.
+var unaryMethodsWithoutAuth = map[string]bool{
+	"/banking.v1.BankingService/Login":       true,
+	"/banking.v1.BankingService/OpenAccount": true,
+}
+
+// authUnaryInterceptor authenticates every unary RPC except Login and
+// OpenAccount, resolving the bearer token once and handing handlers the
+// account ID via accountIDFromContext. Handlers still check the ID against
+// the one they were asked to act on, so a valid token can't be used to
+// touch another account.
+func (s *bankingServer) authUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if unaryMethodsWithoutAuth[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	accountID, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, accountIDContextKey{}, accountID), req)
+}
+
+// accountIDFromContext reads the account ID authUnaryInterceptor stored in
+// ctx for the current caller.
+func accountIDFromContext(ctx context.Context) (uint, error) {
+	accountID, ok := ctx.Value(accountIDContextKey{}).(uint)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	return accountID, nil
+}
+
+func bearerToken(authHeader string) string {
+	const prefix = "Bearer "
+	if len(authHeader) > len(prefix) && authHeader[:len(prefix)] == prefix {
+		return authHeader[len(prefix):]
+	}
+	return authHeader
+}
+
+func newBearerToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, banking.ErrAccountNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, banking.ErrAccountExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, banking.ErrWrongPassword):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, banking.ErrInsufficientFunds):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, banking.ErrWalletNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, banking.ErrWalletExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toProtoAccount(a banking.Account) *bankingv1.Account {
+	return &bankingv1.Account{
+		Id:        uint64(a.ID),
+		Username:  a.Username,
+		FullName:  a.FullName,
+		LastLogin: a.LastLogin.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+func toProtoWallet(w banking.WalletBalance) *bankingv1.Wallet {
+	return &bankingv1.Wallet{
+		Id:        uint64(w.ID),
+		AccountId: uint64(w.AccountID),
+		Currency:  w.Currency,
+		Balance:   w.Balance,
+	}
+}
+
+func toProtoTransaction(t banking.Transaction) *bankingv1.Transaction {
+	return &bankingv1.Transaction{
+		Id:             uint64(t.ID),
+		Time:           t.Time.Format("2006-01-02T15:04:05Z07:00"),
+		Counterparty:   t.Counterparty,
+		Amount:         t.Amount,
+		Currency:       t.Currency,
+		ClosingBalance: t.ClosingBalance,
+		Message:        t.Message,
+		Type:           t.Type,
+		FxRate:         t.FXRate,
+		Fee:            t.Fee,
+	}
+}
@@ -0,0 +1,61 @@
+// Package config resolves which database backend cli-bank should talk to,
+// from environment variables, so operators can point the CLI and bankd at
+// sqlite, Postgres or MySQL without a rebuild.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Driver names accepted by BANK_DB_DRIVER.
+const (
+	Sqlite   = "sqlite"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// Defaults match the CLI's historical behaviour: a local sqlite file.
+const (
+	DefaultDriver = Sqlite
+	DefaultDSN    = "db.sqlite"
+)
+
+// DB is a resolved driver/DSN pair.
+type DB struct {
+	Driver string
+	DSN    string
+}
+
+// FromEnv reads BANK_DB_DRIVER and BANK_DB_DSN, falling back to a local
+// sqlite file so existing setups keep working unconfigured.
+func FromEnv() DB {
+	driver := os.Getenv("BANK_DB_DRIVER")
+	if driver == "" {
+		driver = DefaultDriver
+	}
+	dsn := os.Getenv("BANK_DB_DSN")
+	if dsn == "" {
+		dsn = DefaultDSN
+	}
+	return DB{Driver: driver, DSN: dsn}
+}
+
+// Open connects to the database using the GORM driver matching c.Driver.
+func (c DB) Open() (*gorm.DB, error) {
+	switch c.Driver {
+	case Sqlite:
+		return gorm.Open(sqlite.Open(c.DSN), &gorm.Config{})
+	case Postgres:
+		return gorm.Open(postgres.Open(c.DSN), &gorm.Config{})
+	case MySQL:
+		return gorm.Open(mysql.Open(c.DSN), &gorm.Config{})
+	default:
+		return nil, fmt.Errorf("config: unknown BANK_DB_DRIVER %q", c.Driver)
+	}
+}
@@ -0,0 +1,167 @@
+// Package migrations defines and applies the banking schema as a versioned,
+// forward-only sequence, replacing the ad-hoc AutoMigrate calls the CLI and
+// bankd used to run on every startup. Run it via the `bank migrate`
+// subcommand before pointing a new database at either binary.
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. ID must be unique and sort in
+// the order migrations should run, e.g. "0001_create_accounts".
+type Migration struct {
+	ID string
+	Up func(*gorm.DB) error
+}
+
+// schemaMigration records that a Migration has already been applied.
+type schemaMigration struct {
+	ID        string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// Each Migration below operates on a model snapshot local to this package,
+// not the live banking.Account/Wallet/... structs. A migration's job is to
+// leave behind the exact columns its ID says it adds; if it imported the
+// live struct instead, a later, unrelated field added to that struct would
+// silently get swept into an already-shipped migration the next time
+// AutoMigrate ran it. Never edit a snapshot once its migration has shipped:
+// add a new snapshot and a new Migration instead.
+
+// accountV1 is the column set 0001_create_accounts creates.
+type accountV1 struct {
+	ID        uint `gorm:"primaryKey"`
+	FullName  string
+	Username  string `gorm:"unique;index"`
+	Password  string
+	LastLogin time.Time
+	CreatedAt time.Time
+}
+
+func (accountV1) TableName() string { return "accounts" }
+
+// walletV1 is the column set 0002_create_wallets creates.
+type walletV1 struct {
+	ID        uint   `gorm:"primaryKey"`
+	AccountID uint   `gorm:"uniqueIndex:idx_account_currency"`
+	Currency  string `gorm:"uniqueIndex:idx_account_currency"`
+}
+
+func (walletV1) TableName() string { return "wallets" }
+
+// transactionV1 is the column set 0003_create_transactions creates.
+type transactionV1 struct {
+	ID             uint   `gorm:"primaryKey"`
+	TransferID     string `gorm:"index"`
+	Time           time.Time
+	Counterparty   string
+	Amount         float64
+	Currency       string
+	ClosingBalance float64
+	Message        string
+	Type           string
+	FXRate         float64
+	Fee            float64
+	AccountID      uint
+	WalletID       uint
+}
+
+func (transactionV1) TableName() string { return "transactions" }
+
+// ledgerEntryV1 and idempotencyKeyV1 are the column sets 0004_create_ledger
+// creates.
+type ledgerEntryV1 struct {
+	ID         uint   `gorm:"primaryKey"`
+	TransferID string `gorm:"index"`
+	AccountID  uint
+	WalletID   uint `gorm:"index"`
+	Amount     float64
+	Direction  string
+	Currency   string
+	PostedAt   time.Time
+}
+
+func (ledgerEntryV1) TableName() string { return "ledger_entries" }
+
+type idempotencyKeyV1 struct {
+	ID              uint   `gorm:"primaryKey"`
+	ClientRequestID string `gorm:"uniqueIndex"`
+	TransferID      string
+}
+
+func (idempotencyKeyV1) TableName() string { return "idempotency_keys" }
+
+// accountRuleScriptV1 is the column set 0005_add_account_rule_script adds
+// to the accounts table created by accountV1.
+type accountRuleScriptV1 struct {
+	RuleScript   string `gorm:"type:text"`
+	RulesEnabled bool
+}
+
+func (accountRuleScriptV1) TableName() string { return "accounts" }
+
+// All is the ordered history of every schema change the banking database has
+// needed. Never edit a Migration once it has shipped: add a new one instead.
+var All = []Migration{
+	{
+		ID: "0001_create_accounts",
+		Up: func(db *gorm.DB) error { return db.AutoMigrate(&accountV1{}) },
+	},
+	{
+		ID: "0002_create_wallets",
+		Up: func(db *gorm.DB) error { return db.AutoMigrate(&walletV1{}) },
+	},
+	{
+		ID: "0003_create_transactions",
+		Up: func(db *gorm.DB) error { return db.AutoMigrate(&transactionV1{}) },
+	},
+	{
+		ID: "0004_create_ledger",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&ledgerEntryV1{}, &idempotencyKeyV1{})
+		},
+	},
+	{
+		ID: "0005_add_account_rule_script",
+		Up: func(db *gorm.DB) error {
+			m := db.Migrator()
+			if err := m.AddColumn(&accountRuleScriptV1{}, "RuleScript"); err != nil {
+				return err
+			}
+			return m.AddColumn(&accountRuleScriptV1{}, "RulesEnabled")
+		},
+	},
+}
+
+// Run applies every Migration in All that hasn't already run against db, in
+// order, recording each as it completes so a later Run is a no-op.
+func Run(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	for _, m := range All {
+		var applied bool
+		if err := db.Model(&schemaMigration{}).
+			Select("count(*) > 0").
+			Where("id = ?", m.ID).
+			Find(&applied).Error; err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error
+		}); err != nil {
+			return fmt.Errorf("migration %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
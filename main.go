@@ -2,46 +2,27 @@ package main
 
 import (
 	"bufio"
-	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
-)
-
-type Account struct {
-	ID        uint      `json:"ID" gorm:"primaryKey"`
-	FullName  string    `json:"fullName"`
-	Username  string    `json:"username" gorm:"unique;index"`
-	Password  string    `json:"password"`
-	Balance   float64   `json:"balance"`
-	LastLogin time.Time `json:"lastLogin"`
-	CreatedAt time.Time
-}
 
-type Transaction struct {
-	ID             uint      `json:"ID" gorm:"primaryKey"`
-	Time           time.Time `json:"time"`
-	Counterparty   string    `json:"counterparty"`
-	Amount         float64   `json:"amount"`
-	ClosingBalance float64   `json:"closingBalance"`
-	Message        string    `json:"message"`
-	Type           string    `json:"type"`
-	AccountID      uint
-	//Account        Account `gorm:"foreignKey:AccountID"`
-}
+	"github.com/sainak/cli-bank/banking"
+	"github.com/sainak/cli-bank/internal/config"
+	"github.com/sainak/cli-bank/internal/migrations"
+)
 
 var (
-	db                *gorm.DB
-	dbErr             error
-	currentAccount    Account
-	previousLastLogin time.Time
+	svc            *banking.Service
+	currentAccount banking.Account
+	lastLogin      time.Time
+
+	// walletStore is set only in --wallet mode, so deleteAccount knows to
+	// scrub the wallet file rather than leaving an empty one behind.
+	walletStore *banking.WalletFileStore
 )
 
 // helper to read string with spaces from the stdin buffer
@@ -92,6 +73,19 @@ func readAmount() float64 {
 	}
 }
 
+// helper to read a currency code, defaulting to banking.DefaultCurrency when
+// the user just presses enter
+func readCurrency() string {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Enter currency [%s]: ", banking.DefaultCurrency)
+	input, _ := reader.ReadString('\n')
+	input = strings.ToUpper(strings.TrimSpace(input))
+	if input == "" {
+		return banking.DefaultCurrency
+	}
+	return input
+}
+
 func printSelectMenu(options []string) {
 	fmt.Println("Select:")
 	for i, option := range options {
@@ -99,72 +93,38 @@ func printSelectMenu(options []string) {
 	}
 }
 
-func hash(s string) string {
-	h := sha256.New()
-	h.Write([]byte(s))
-	sha := base64.URLEncoding.EncodeToString(h.Sum(nil))
-	return sha
-}
-
 func login() error {
 	username := readString("Enter username: ")
-	var account Account
-	err := db.Where("username = ?", username).First(&account).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("user not found")
-		}
-		log.Fatalln(err)
-	}
 
 	incorrectPasswordCount := 0
 	for {
 		password := readString("Enter password: ")
-		if hash(password) != account.Password {
-			if incorrectPasswordCount < 3 {
-				fmt.Println("Incorrect Password")
-				incorrectPasswordCount++
-				continue
-			} else {
+		account, prevLogin, err := svc.Login(username, password)
+		if err != nil {
+			if errors.Is(err, banking.ErrWrongPassword) {
+				if incorrectPasswordCount < 3 {
+					fmt.Println("Incorrect Password")
+					incorrectPasswordCount++
+					continue
+				}
 				return errors.New("exceeded maximum number of login attempts")
 			}
+			if errors.Is(err, banking.ErrAccountNotFound) {
+				return errors.New("user not found")
+			}
+			log.Fatalln(err)
 		}
+		currentAccount = account
+		lastLogin = prevLogin
 		break
 	}
-	previousLastLogin = account.LastLogin
-	account.LastLogin = time.Now()
-	err = db.Save(&account).Error
-	if err != nil {
-		log.Fatalln(err)
-	}
-	currentAccount = account
-	fmt.Println("Hi, ", account.FullName)
+	fmt.Println("Hi, ", currentAccount.FullName)
 	return nil
 }
 
 func createAccount() {
 	username := readString("Enter username: ")
-
-	// sanity check
-	var exists bool
-	err := db.Model(Account{}).
-		Select("count(*) > 0").
-		Where("username = ?", username).
-		Find(&exists).Error
-	if err != nil {
-		log.Fatalln(err)
-	}
-	if exists {
-		fmt.Println("ERROR: account already taken")
-		return
-	}
-
-	account := Account{
-		Username: username,
-		Balance:  1000, // joining amount
-	}
-
-	account.FullName = readString("Enter your full name: ")
+	fullName := readString("Enter your full name: ")
 
 	var p1, p2 string
 	for {
@@ -175,155 +135,185 @@ func createAccount() {
 		}
 		fmt.Println("ERROR: passwords do not match")
 	}
-	account.Password = hash(p1)
-	err = db.Create(&account).Error
+
+	_, err := svc.CreateAccount(username, fullName, p1)
 	if err != nil {
+		if errors.Is(err, banking.ErrAccountExists) {
+			fmt.Println("ERROR: account already taken")
+			return
+		}
 		log.Fatalln(err)
 	}
 	fmt.Println("Account created successfully")
-	return
 }
 
 func listTransactions(start int, end int) {
-	var transactions []Transaction
-	err := db.Where("account_id = ?", currentAccount.ID).Find(&transactions).Error
+	transactions, err := svc.ListTransactions(currentAccount.ID, start, end)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	l := len(transactions)
-	if end == 0 {
-		end = l
-	}
-
-	for i := start; i < end; i++ {
-		if i == l {
-			break
-		}
-		fmt.Println(transactions[i]) //todo pretty print?
+	for _, t := range transactions {
+		fmt.Println(t) //todo pretty print?
 	}
 }
 
 func checkAccountInfo() {
 	fmt.Println("Name: ", currentAccount.FullName)
 	fmt.Println("Username: ", currentAccount.Username)
-	fmt.Println("Current account balance: ", currentAccount.Balance)
-	if !previousLastLogin.IsZero() {
-		fmt.Println("Last login: ", previousLastLogin.Format("2006-01-02 15:04:05"))
+	listBalances()
+	if !lastLogin.IsZero() {
+		fmt.Println("Last login: ", lastLogin.Format("2006-01-02 15:04:05"))
 	}
 	fmt.Println("Last 5 transactions: ")
 	listTransactions(0, 5)
 }
 
+func listBalances() {
+	wallets, err := svc.ListBalances(currentAccount.ID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Balances:")
+	for _, w := range wallets {
+		fmt.Printf("  %s %.2f\n", w.Currency, w.Balance)
+	}
+}
+
+func openWallet() {
+	currency := readCurrency()
+	_, err := svc.OpenWallet(currentAccount.ID, currency)
+	if err != nil {
+		if errors.Is(err, banking.ErrWalletExists) {
+			fmt.Println("ERROR: you already have a", currency, "sub-account")
+			return
+		}
+		log.Fatalln(err)
+	}
+	fmt.Println(currency, "sub-account opened successfully")
+}
+
 func depositCash() {
+	currency := readCurrency()
 	amount := readAmount()
-	err := db.Transaction(func(tx *gorm.DB) error {
-		currentAccount.Balance += amount
-		if err := tx.Save(&currentAccount).Error; err != nil {
-			return err
+	wallet, err := svc.Deposit(currentAccount.ID, currency, amount, "")
+	if err != nil {
+		if errors.Is(err, banking.ErrWalletNotFound) {
+			fmt.Println("ERROR: no", currency, "sub-account, open one first")
+			return
 		}
-		if err := tx.Create(&Transaction{
-			Time:           time.Now(),
-			Amount:         amount,
-			ClosingBalance: currentAccount.Balance,
-			Message:        "credited via cash deposit",
-			Type:           "C",
-			AccountID:      currentAccount.ID,
-		}).Error; err != nil {
-			return err
+		if errors.Is(err, banking.ErrRuleDenied) || errors.Is(err, banking.ErrTwoFactorRequired) {
+			fmt.Println("ERROR: ", err)
+			return
 		}
-		return nil
-	})
-	if err != nil {
 		log.Fatalln(err)
 	}
-	fmt.Printf("%.2f successfully deposited to your account\n", amount)
-	fmt.Println("Closing balance: ", currentAccount.Balance)
+	fmt.Printf("%.2f %s successfully deposited to your account\n", amount, currency)
+	fmt.Println("Closing balance: ", wallet.Balance)
 }
 
 func withdrawCash() {
+	currency := readCurrency()
 	amount := readAmount()
-	err := db.Transaction(func(tx *gorm.DB) error {
-		currentAccount.Balance -= amount
-		if err := tx.Save(&currentAccount).Error; err != nil {
-			return err
+	wallet, err := svc.Withdraw(currentAccount.ID, currency, amount, "")
+	if err != nil {
+		if errors.Is(err, banking.ErrWalletNotFound) {
+			fmt.Println("ERROR: no", currency, "sub-account, open one first")
+			return
 		}
-		if err := tx.Create(&Transaction{
-			Time:           time.Now(),
-			Amount:         amount,
-			ClosingBalance: currentAccount.Balance,
-			Message:        "debited via cash withdrawal",
-			Type:           "D",
-			AccountID:      currentAccount.ID,
-		}).Error; err != nil {
-			return err
+		if errors.Is(err, banking.ErrInsufficientFunds) {
+			fmt.Println("ERROR: insufficient funds")
+			return
+		}
+		if errors.Is(err, banking.ErrRuleDenied) || errors.Is(err, banking.ErrTwoFactorRequired) {
+			fmt.Println("ERROR: ", err)
+			return
 		}
-		return nil
-	})
-	if err != nil {
 		log.Fatalln(err)
 	}
-	fmt.Printf("%.2f successfully withdrawn from your account\n", amount)
-	fmt.Println("Closing balance: ", currentAccount.Balance)
+	fmt.Printf("%.2f %s successfully withdrawn from your account\n", amount, currency)
+	fmt.Println("Closing balance: ", wallet.Balance)
 }
 
 func transferMoney() {
-	var receiver Account
-	r := readString("Enter receiver's username:")
-	result := db.Where("username = ?", r).First(&receiver)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+	fromCurrency := readCurrency()
+	receiver := readString("Enter receiver's username:")
+	toCurrency := readCurrency()
+	amount := readAmount()
+
+	from, _, err := svc.Transfer(currentAccount.ID, fromCurrency, receiver, toCurrency, amount, "")
+	if err != nil {
+		if errors.Is(err, banking.ErrAccountNotFound) {
 			fmt.Println("ERROR: receiver not found")
 			return
 		}
-		log.Fatalln(result.Error)
+		if errors.Is(err, banking.ErrWalletNotFound) {
+			fmt.Println("ERROR: sub-account not found on sender or receiver")
+			return
+		}
+		if errors.Is(err, banking.ErrInsufficientFunds) {
+			fmt.Println("ERROR: insufficient funds")
+			return
+		}
+		if errors.Is(err, banking.ErrRuleDenied) || errors.Is(err, banking.ErrTwoFactorRequired) {
+			fmt.Println("ERROR: ", err)
+			return
+		}
+		log.Fatalln(err)
 	}
+	fmt.Printf("%.2f %s successfully sent to %s\n", amount, fromCurrency, receiver)
+	fmt.Println("Closing balance: ", from.Balance)
+}
 
-	amount := readAmount()
-	if currentAccount.Balance < amount {
-		fmt.Println("ERROR: insufficient funds")
-		return
+// readRuleScript reads a path to a Lua rules script from stdin and returns
+// its contents.
+func readRuleScript(message string) (string, error) {
+	path := readString(message)
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
 	}
+	return string(script), nil
+}
 
-	currentTime := time.Now()
+func editRules() {
+	script, err := readRuleScript("Enter path to your rules script: ")
+	if err != nil {
+		fmt.Println("ERROR: ", err)
+		return
+	}
+	if err := svc.SetRuleScript(currentAccount.ID, script); err != nil {
+		fmt.Println("ERROR: ", err)
+		return
+	}
+	fmt.Println("Rules script saved and enabled")
+}
 
-	err := db.Transaction(func(tx *gorm.DB) error {
-		currentAccount.Balance -= amount
-		receiver.Balance += amount
-		if err := tx.Save(&currentAccount).Error; err != nil {
-			return err
-		}
-		if err := tx.Save(&receiver).Error; err != nil {
-			return err
-		}
-		if err := tx.Create(&Transaction{
-			Time:           currentTime,
-			Counterparty:   receiver.Username,
-			Amount:         amount,
-			ClosingBalance: currentAccount.Balance,
-			Message:        fmt.Sprintf("transferred to %s", receiver.Username),
-			Type:           "D",
-			AccountID:      currentAccount.ID,
-		}).Error; err != nil {
-			return err
-		}
-		if err := tx.Create(&Transaction{
-			Time:           currentTime,
-			Counterparty:   currentAccount.Username,
-			Amount:         amount,
-			ClosingBalance: receiver.Balance,
-			Message:        fmt.Sprintf("received from %s", currentAccount.Username),
-			Type:           "C",
-			AccountID:      receiver.ID,
-		}).Error; err != nil {
-			return err
-		}
-		return nil
-	})
+func testRules() {
+	script, err := readRuleScript("Enter path to the rules script to test: ")
 	if err != nil {
+		fmt.Println("ERROR: ", err)
+		return
+	}
+	op := banking.PendingOperation{
+		Type:   readString("Operation type to simulate [deposit/withdraw/transfer]: "),
+		Amount: readAmount(),
+		Time:   time.Now(),
+	}
+	if op.Type == "transfer" {
+		op.Counterparty = readString("Counterparty username to simulate: ")
+	}
+	if err := svc.TestRuleScript(currentAccount.ID, script, op); err != nil {
+		fmt.Println("Script would REJECT this operation:", err)
+		return
+	}
+	fmt.Println("Script would ALLOW this operation")
+}
+
+func disableRules() {
+	if err := svc.DisableRules(currentAccount.ID); err != nil {
 		log.Fatalln(err)
 	}
-	fmt.Printf("%.2f successfully sent to %s\n", amount, receiver.Username)
-	fmt.Println("Closing balance: ", currentAccount.Balance)
+	fmt.Println("Rules disabled")
 }
 
 func deleteAccount() (d bool) {
@@ -333,10 +323,14 @@ func deleteAccount() (d bool) {
 	if yes2 := readYesNo("Are you sure you want to delete your account?: "); !yes2 {
 		return
 	}
-	err := db.Delete(&currentAccount).Error
-	if err != nil {
+	if err := svc.DeleteAccount(currentAccount.ID); err != nil {
 		log.Fatalln(err)
 	}
+	if walletStore != nil {
+		if err := walletStore.Scrub(); err != nil {
+			log.Fatalln(err)
+		}
+	}
 	return true
 }
 
@@ -354,6 +348,11 @@ func accountLoop() {
 		"Deposit cash",
 		"Withdraw cash",
 		"Transfer money",
+		"Open currency sub-account",
+		"List balances",
+		"Edit rules script",
+		"Test rules script",
+		"Disable rules",
 		"Delete account",
 		"Logout",
 	})
@@ -372,10 +371,20 @@ func accountLoop() {
 		case "5":
 			transferMoney()
 		case "6":
+			openWallet()
+		case "7":
+			listBalances()
+		case "8":
+			editRules()
+		case "9":
+			testRules()
+		case "10":
+			disableRules()
+		case "11":
 			if ok := deleteAccount(); ok {
 				return
 			}
-		case "7":
+		case "12":
 			return
 		default:
 			fmt.Println("Enter a valid choice")
@@ -384,17 +393,118 @@ func accountLoop() {
 	}
 }
 
+// runMigrate backs the `bank migrate` subcommand: it brings the configured
+// database's schema up to date and exits, without starting the interactive
+// CLI.
+func runMigrate() {
+	db, err := config.FromEnv().Open()
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	if err := migrations.Run(db); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	fmt.Println("migrations applied")
+}
+
+// runWalletMode backs the `--wallet <path>` mode: instead of the usual
+// shared, multi-user SQL database, the one account using this mode lives in
+// a single AES-256-GCM encrypted file at path, keyed by an Argon2id-derived
+// passphrase — modeled on crypto-wallet CLIs rather than cli-bank's usual
+// client/server setup. It bootstraps a fresh wallet file if path doesn't
+// exist yet, otherwise unlocks the existing one.
+func runWalletMode(path string) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		createWalletFile(path)
+		return
+	}
+
+	passphrase := readString("Enter wallet passphrase: ")
+	store, err := banking.OpenWalletFileStore(path, passphrase)
+	if err != nil {
+		if errors.Is(err, banking.ErrWrongPassphrase) {
+			fmt.Println("ERROR: wrong passphrase")
+			return
+		}
+		log.Fatalln(err)
+	}
+	walletStore = store
+
+	svc, err = banking.NewService(store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := svc.CheckConsistency(); err != nil {
+		log.Fatalln(err)
+	}
+	accountLoop()
+}
+
+// createWalletFile bootstraps a brand-new --wallet file: it collects the
+// same account details createAccount does, plus a separate passphrase that
+// encrypts the file, then hands off to the normal account session.
+func createWalletFile(path string) {
+	username := readString("Enter username: ")
+	fullName := readString("Enter your full name: ")
+
+	var p1, p2 string
+	for {
+		p1 = readString("Enter new password: ")
+		p2 = readString("Renter password: ")
+		if p1 == p2 {
+			break
+		}
+		fmt.Println("ERROR: passwords do not match")
+	}
+
+	var w1, w2 string
+	for {
+		w1 = readString("Enter wallet passphrase: ")
+		w2 = readString("Renter wallet passphrase: ")
+		if w1 == w2 {
+			break
+		}
+		fmt.Println("ERROR: passphrases do not match")
+	}
+
+	store, err := banking.NewWalletFileStore(path, w1)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	walletStore = store
+
+	svc, err = banking.NewService(store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if _, err := svc.CreateAccount(username, fullName, p1); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Wallet file created at", path)
+	accountLoop()
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate()
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "--wallet" {
+		runWalletMode(os.Args[2])
+		return
+	}
 
-	db, dbErr = gorm.Open(sqlite.Open("db.sqlite"), &gorm.Config{})
-	if dbErr != nil {
+	db, err := config.FromEnv().Open()
+	if err != nil {
 		panic("failed to connect database")
 	}
 
-	// Migrate the schema
-	err := db.AutoMigrate(&Account{}, &Transaction{})
+	svc, err = banking.NewService(banking.NewGormStore(db))
 	if err != nil {
-		return
+		panic(err)
+	}
+	if err := svc.CheckConsistency(); err != nil {
+		panic(err)
 	}
 
 	fmt.Printf("Welcome\n-------\n\n")